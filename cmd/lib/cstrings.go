@@ -0,0 +1,49 @@
+package main
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"unicode/utf8"
+	"unsafe"
+)
+
+// FreeString libera una cadena devuelta por la librería. Todas las funciones que devuelven char*
+// reservan memoria que el llamador debe liberar con FreeString.
+//
+//export FreeString
+func FreeString(str *C.char) {
+	C.free(unsafe.Pointer(str))
+}
+
+// copyToBuffer copia value en el buffer del llamador de size bytes, terminado en NUL y sin cortar
+// caracteres UTF-8. Devuelve la longitud en bytes de value: si es mayor o igual a size el valor se
+// truncó y el llamador puede reintentar con un buffer de al menos ese tamaño más uno.
+// Con buf nulo o size 0 solo se devuelve la longitud.
+func copyToBuffer(value string, buf *C.char, size int32) int32 {
+	if buf == nil || size <= 0 {
+		return int32(len(value))
+	}
+
+	// size es int32, por lo que el buffer nunca excede el arreglo
+	return copyString((*[1<<31 - 1]byte)(unsafe.Pointer(buf))[:size:size], value)
+}
+
+// copyString es copyToBuffer sobre un slice (los tests no pueden usar cgo)
+func copyString(dst []byte, value string) int32 {
+	if len(dst) == 0 {
+		return int32(len(value))
+	}
+
+	n := len(value)
+	if n > len(dst)-1 {
+		n = len(dst) - 1
+		for n > 0 && !utf8.RuneStart(value[n]) {
+			n--
+		}
+	}
+
+	copy(dst, value[:n])
+	dst[n] = 0
+	return int32(len(value))
+}
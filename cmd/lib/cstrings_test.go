@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCopyString(t *testing.T) {
+	tests := []struct {
+		value string
+		size  int
+		want  string
+	}{
+		{"71234567890123", 15, "71234567890123"},
+		{"71234567890123", 14, "7123456789012"},
+		{"20240131", 100, "20240131"},
+		{"", 1, ""},
+		{"abc", 1, ""},
+		// No se corta la ñ (2 bytes) por la mitad
+		{"año", 3, "a"},
+		{"año", 4, "añ"},
+		{"abc", 0, ""},
+	}
+
+	for _, tt := range tests {
+		dst := bytes.Repeat([]byte{0xff}, tt.size)
+		if got := copyString(dst, tt.value); got != int32(len(tt.value)) {
+			t.Errorf("copyString(%q, %d) = %d, want %d", tt.value, tt.size, got, len(tt.value))
+		}
+		if tt.size == 0 {
+			continue
+		}
+		if i := bytes.IndexByte(dst, 0); i < 0 || string(dst[:i]) != tt.want {
+			t.Errorf("copyString(%q, %d) wrote %q, want %q", tt.value, tt.size, dst, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/sisuani/gowsfe/pkg/afip/wsafip"
+	"github.com/sisuani/gowsfe/pkg/afip/wsfe"
+)
+
+// service es el estado de un handle devuelto por CreateWSFEService
+type service struct {
+	cuit   int64
+	wsafip *wsafip.Service
+	wsfe   *wsfe.Service
+
+	mu        sync.Mutex
+	lastError string
+}
+
+// cabRequest interpreta la cabecera JSON de un request. Si no indica el CUIT se usa el de CreateWSFEService.
+func (s *service) cabRequest(data string) (*wsfe.CabRequest, error) {
+	cabRequest := &wsfe.CabRequest{}
+	if err := json.Unmarshal([]byte(data), cabRequest); err != nil {
+		return nil, err
+	}
+	if cabRequest.Cuit == 0 {
+		cabRequest.Cuit = s.cuit
+	}
+	return cabRequest, nil
+}
+
+func (s *service) setError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		s.lastError = ""
+		return
+	}
+	s.lastError = err.Error()
+}
+
+func (s *service) getError() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastError
+}
+
+var (
+	handlesMu  sync.Mutex
+	handles    = make(map[int64]*service)
+	nextHandle int64
+)
+
+func registerService(s *service) int64 {
+	handlesMu.Lock()
+	defer handlesMu.Unlock()
+
+	nextHandle++
+	handles[nextHandle] = s
+	return nextHandle
+}
+
+// lookupService devuelve el servicio del handle. Si no existe, LastError(handle) lo informa.
+func lookupService(handle int64) (*service, bool) {
+	handlesMu.Lock()
+	defer handlesMu.Unlock()
+
+	s, ok := handles[handle]
+	return s, ok
+}
+
+// lastError devuelve el último error del handle, o el error de un handle inexistente
+func lastError(handle int64) string {
+	s, ok := lookupService(handle)
+	if !ok {
+		return fmt.Sprintf("handle %d inexistente", handle)
+	}
+	return s.getError()
+}
+
+func removeService(handle int64) bool {
+	handlesMu.Lock()
+	defer handlesMu.Unlock()
+
+	if _, ok := handles[handle]; !ok {
+		return false
+	}
+	delete(handles, handle)
+	return true
+}
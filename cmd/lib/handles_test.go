@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestServiceCabRequest(t *testing.T) {
+	s := &service{cuit: 20111111112}
+
+	tests := []struct {
+		data     string
+		wantCuit int64
+		wantErr  bool
+	}{
+		{`{"ptoVta": 1, "cbteTipo": 6}`, 20111111112, false},
+		{`{"cuit": 30500010912, "ptoVta": 1, "cbteTipo": 6}`, 30500010912, false},
+		{`{"cuit": "20111111112"}`, 0, true},
+	}
+
+	for _, tt := range tests {
+		cabRequest, err := s.cabRequest(tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("cabRequest(%s) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && cabRequest.Cuit != tt.wantCuit {
+			t.Errorf("cabRequest(%s) Cuit = %d, want %d", tt.data, cabRequest.Cuit, tt.wantCuit)
+		}
+	}
+}
+
+func TestLastError(t *testing.T) {
+	s := &service{}
+	handle := registerService(s)
+	defer removeService(handle)
+
+	s.setError(errors.New("token vencido"))
+	if got := lastError(handle); got != "token vencido" {
+		t.Errorf("lastError(%d) = %q, want %q", handle, got, "token vencido")
+	}
+
+	// Un handle inexistente no comparte el error con otros handles
+	if got, want := lastError(handle+1), fmt.Sprintf("handle %d inexistente", handle+1); got != want {
+		t.Errorf("lastError(%d) = %q, want %q", handle+1, got, want)
+	}
+	if got := lastError(handle); got != "token vencido" {
+		t.Errorf("lastError(%d) = %q after an invalid handle, want %q", handle, got, "token vencido")
+	}
+}
@@ -3,9 +3,8 @@ package main
 import "C"
 
 import (
-	"crypto/x509"
+	"context"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 	"log"
 	"os"
@@ -13,12 +12,9 @@ import (
 
 	"github.com/sisuani/gowsfe/pkg/afip/wsafip"
 	"github.com/sisuani/gowsfe/pkg/afip/wsfe"
+	"github.com/sisuani/gowsfe/pkg/certs"
 )
 
-var lastError string
-var wsafipService *wsafip.Service
-var wsfeService *wsfe.Service
-
 func writeToLog(message string) error {
 	file, err := os.OpenFile("gowsfe.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -36,137 +32,275 @@ func writeToLog(message string) error {
 }
 
 //export GetCertExpiryDays
-func GetCertExpiryDays(certsPath string) int64 {
-	crtPath := certsPath + "/cert.crt"
+func GetCertExpiryDays(certsPathCchar *C.char) int64 {
+	certsPath := C.GoString(certsPathCchar)
 
 	writeToLog("GetCertExpiryDays()")
 
-	certData, err := os.ReadFile(crtPath)
+	report, err := certs.Inspect(certsPath+"/cert.crt", "")
 	if err != nil {
-		writeToLog(fmt.Sprintf("  |_ Error al leer el certificado: %v", err))
+		writeToLog(fmt.Sprintf("  |_ %v", err))
 		return -1
 	}
 
-	block, _ := pem.Decode(certData)
-	if block == nil {
-		writeToLog("  |_ No se pudo decodificar el certificado PEM")
-		return -1
+	if report.Expired {
+		writeToLog("  |_ El certificado ya ha expirado")
+		return 0
 	}
 
-	cert, err := x509.ParseCertificate(block.Bytes)
+	writeToLog(fmt.Sprintf("  |_ Dias para que el certificado expire: %v", report.DaysRemaining))
+	return report.DaysRemaining
+}
+
+// copyError escribe el mensaje de err (vacío si es nil) en el buffer de error del llamador.
+// Las funciones que no reciben un handle informan sus errores así en lugar de LastError.
+func copyError(err error, errBuf *C.char, errSize int32) {
+	msg := ""
 	if err != nil {
-		writeToLog(fmt.Sprintf("  |_ Error al parsear el certificado: %v", err))
-		return -1
+		msg = err.Error()
 	}
+	copyToBuffer(msg, errBuf, errSize)
+}
 
-	now := time.Now()
-	expiry := cert.NotAfter
-	daysRemaining := int64(expiry.Sub(now).Hours() / 24)
+func inspectCert(certsPath string) (string, error) {
+	writeToLog("InspectCert()")
 
-	if daysRemaining < 0 {
-		writeToLog("  |_ El certificado ya ha expirado")
-		return 0
+	report, err := certs.Inspect(certsPath+"/cert.crt", certsPath+"/cert.key")
+	if err != nil {
+		writeToLog(fmt.Sprintf("  |_ error: %s", err))
+		return "", err
+	}
+
+	info, err := json.Marshal(report)
+	if err != nil {
+		return "", err
 	}
 
-	writeToLog(fmt.Sprintf("  |_ Dias para que el certificado expire: %v", daysRemaining))
-	return daysRemaining
+	writeToLog(fmt.Sprintf("  |_ %s", info))
+	return string(info), nil
 }
 
+// InspectCert devuelve el informe JSON del certificado de certsPath, o una cadena vacía si no se
+// pudo inspeccionar. En ese caso el error se escribe en errBuf (de errSize bytes, puede ser nulo).
+//
+//export InspectCert
+func InspectCert(certsPathCchar *C.char, errBuf *C.char, errSize int32) *C.char {
+	info, err := inspectCert(C.GoString(certsPathCchar))
+	copyError(err, errBuf, errSize)
+	return C.CString(info)
+}
+
+// InspectCertBuf es como InspectCert pero escribe el resultado en el buffer del llamador
+// y devuelve su longitud (ver copyToBuffer)
+//
+//export InspectCertBuf
+func InspectCertBuf(certsPathCchar *C.char, buf *C.char, size int32, errBuf *C.char, errSize int32) int32 {
+	info, err := inspectCert(C.GoString(certsPathCchar))
+	copyError(err, errBuf, errSize)
+	return copyToBuffer(info, buf, size)
+}
+
+// CreateWSFEService crea un servicio para el certificado de certsPath y devuelve su handle,
+// o 0 si no se pudo crear, con el error en errBuf (de errSize bytes, puede ser nulo).
+// environment es 0 para homologación y 1 para producción. cuit se usa en los requests que no indican el CUIT.
+//
 //export CreateWSFEService
-func CreateWSFEService(certsPath string, cuit int64) bool {
+func CreateWSFEService(certsPathCchar *C.char, cuit int64, environment int32, errBuf *C.char, errSize int32) int64 {
+	handle, err := createService(C.GoString(certsPathCchar), cuit, environment)
+	copyError(err, errBuf, errSize)
+	return handle
+}
+
+func createService(certsPath string, cuit int64, environment int32) (int64, error) {
+
 	crt := certsPath + "/" + "cert.crt"
 	key := certsPath + "/" + "cert.key"
 
 	writeToLog("CreateWSFEService()")
+	writeToLog(fmt.Sprintf("  |_ cuit: %d", cuit))
 	writeToLog(fmt.Sprintf("  |_ crt: %s", crt))
 	writeToLog(fmt.Sprintf("  |_ key: %s", key))
 
-	wsafipService = wsafip.NewService(wsafip.PRODUCTION, crt, key)
-	var err error
-	token, sign, _, err := wsafipService.GetLoginTicket("wsfe")
+	wsafipEnvironment, wsfeEnvironment := wsafip.TESTING, wsfe.TESTING
+	if environment == int32(wsafip.PRODUCTION) {
+		wsafipEnvironment, wsfeEnvironment = wsafip.PRODUCTION, wsfe.PRODUCTION
+	}
+
+	wsafipService := wsafip.NewService(wsafipEnvironment, crt, key, wsafip.WithTicketStore(wsafip.NewFileTicketStore(certsPath)))
+	token, sign, _, err := wsafipService.GetLoginTicket(wsfe.ServiceName)
 	if err != nil {
-		lastError = err.Error()
-		return false
+		writeToLog(fmt.Sprintf("  |_ error: %s", err))
+		return 0, err
 	}
 
-	wsfeService = wsfe.NewService(wsfe.PRODUCTION, token, sign)
-	return true
+	// El ticket se renueva automáticamente al vencer
+	wsfeService := wsfe.NewService(wsfeEnvironment, token, sign, wsfe.WithCredentialsProvider(wsfe.NewAFIPCredentials(wsafipService)))
+
+	handle := registerService(&service{cuit: cuit, wsafip: wsafipService, wsfe: wsfeService})
+	writeToLog(fmt.Sprintf("  |_ handle: %d", handle))
+	return handle, nil
+}
+
+// DestroyService libera el handle. Devuelve false si el handle no existe.
+//
+//export DestroyService
+func DestroyService(handle int64) bool {
+	writeToLog(fmt.Sprintf("DestroyService(%d)", handle))
+	return removeService(handle)
+}
+
+func ticketInfo(handle int64) string {
+	s, ok := lookupService(handle)
+	if !ok {
+		return ""
+	}
+	s.setError(nil)
+
+	writeToLog(fmt.Sprintf("GetTicketInfo(%d)", handle))
+
+	ticket, err := s.wsafip.Ticket(context.Background(), wsfe.ServiceName)
+	if err != nil {
+		s.setError(err)
+		writeToLog(fmt.Sprintf("  |_ error: %s", err))
+		return ""
+	}
+
+	info, err := json.Marshal(struct {
+		*wsafip.LoginTicket
+		ExpiresIn int64 `json:"expiresIn"`
+	}{ticket, int64(ticket.ExpiresIn().Seconds())})
+	if err != nil {
+		s.setError(err)
+		return ""
+	}
+
+	writeToLog(fmt.Sprintf("  |_ vencimiento: %s", ticket.ExpirationTime.Format(time.RFC3339)))
+	return string(info)
+}
+
+//export GetTicketInfo
+func GetTicketInfo(handle int64) *C.char {
+	return C.CString(ticketInfo(handle))
+}
+
+// GetTicketInfoBuf es como GetTicketInfo pero escribe el resultado en el buffer del llamador
+//
+//export GetTicketInfoBuf
+func GetTicketInfoBuf(handle int64, buf *C.char, size int32) int32 {
+	return copyToBuffer(ticketInfo(handle), buf, size)
 }
 
 //export GetUltimoComp
-func GetUltimoComp(requestStrCchar *C.char) int64 {
-	lastError = ""
+func GetUltimoComp(handle int64, requestStrCchar *C.char) int64 {
+	s, ok := lookupService(handle)
+	if !ok {
+		return -1
+	}
+	s.setError(nil)
 	requestStr := C.GoString(requestStrCchar)
 
-	writeToLog("GetUltimoComp()")
+	writeToLog(fmt.Sprintf("GetUltimoComp(%d)", handle))
 	writeToLog(fmt.Sprintf("  |_ Request: %s", requestStr))
 
-	cabRequest := wsfe.CabRequest{}
-	if err := json.Unmarshal([]byte(requestStr), &cabRequest); err != nil {
-		lastError = err.Error()
-		writeToLog(fmt.Sprintf("  |_ Error al parsear el request: %s", lastError))
+	cabRequest, err := s.cabRequest(requestStr)
+	if err != nil {
+		s.setError(err)
+		writeToLog(fmt.Sprintf("  |_ Error al parsear el request: %s", err))
 		return -1
 	}
-	cbteNro, err := wsfeService.GetUltimoComp(&cabRequest)
+	cbteNro, err := s.wsfe.GetUltimoComp(cabRequest)
 	writeToLog(fmt.Sprintf("  |_ Ultimo Comprobante (AFIP): %d", cbteNro))
 	if err != nil {
-		lastError = err.Error()
+		s.setError(err)
 		cbteNro = -1
 	}
 	writeToLog(fmt.Sprintf("  |_ Ultimo Comprobante: %d", cbteNro))
 	return int64(cbteNro)
 }
 
-//export CaeRequest
-func CaeRequest(cabRequestCchar, detRequestCchar *C.char) (*C.char, *C.char) {
-	lastError = ""
-	cabRequestStr := C.GoString(cabRequestCchar)
-	detRequestStr := C.GoString(detRequestCchar)
+// requestCAE solicita el CAE. ok es false si hubo un error (ver LastError)
+func requestCAE(handle int64, cabRequestStr, detRequestStr string) (cae string, caeFchVto string, ok bool) {
+	s, ok := lookupService(handle)
+	if !ok {
+		return "", "", false
+	}
+	s.setError(nil)
 
-	writeToLog("CaeRequest()")
+	writeToLog(fmt.Sprintf("CaeRequest(%d)", handle))
 	writeToLog(fmt.Sprintf("  |_ CAB: %s", cabRequestStr))
 	writeToLog(fmt.Sprintf("  |_ DET: %s", detRequestStr))
 
-	cabRequest := wsfe.CabRequest{}
-	err := json.Unmarshal([]byte(cabRequestStr), &cabRequest)
+	cabRequest, err := s.cabRequest(cabRequestStr)
 	if err != nil {
-		lastError = err.Error()
-		return C.CString(""), C.CString("")
+		s.setError(err)
+		return "", "", false
 	}
 
 	caeRequest := wsfe.CaeRequest{}
 	err = json.Unmarshal([]byte(detRequestStr), &caeRequest)
 	if err != nil {
-		lastError = err.Error()
-		writeToLog(fmt.Sprintf("  |_ error: %s", lastError))
-		return C.CString(""), C.CString("")
+		s.setError(err)
+		writeToLog(fmt.Sprintf("  |_ error: %s", err))
+		return "", "", false
 	}
 
-	cae, caeFchVto, err := wsfeService.CaeRequest(&cabRequest, &caeRequest)
+	cae, caeFchVto, err = s.wsfe.CaeRequest(cabRequest, &caeRequest)
 	if err != nil {
-		lastError = err.Error()
-		writeToLog(fmt.Sprintf("  |_ error: %s", lastError))
+		s.setError(err)
+		writeToLog(fmt.Sprintf("  |_ error: %s", err))
 	}
 
 	writeToLog(fmt.Sprintf("  |_ cae: %s", cae))
 	writeToLog(fmt.Sprintf("  |_ vto: %s", caeFchVto))
+	return cae, caeFchVto, err == nil
+}
+
+//export CaeRequest
+func CaeRequest(handle int64, cabRequestCchar, detRequestCchar *C.char) (*C.char, *C.char) {
+	cae, caeFchVto, _ := requestCAE(handle, C.GoString(cabRequestCchar), C.GoString(detRequestCchar))
 	return C.CString(cae), C.CString(caeFchVto)
 }
 
+// CaeRequestBuf es como CaeRequest pero escribe el CAE y su vencimiento en los buffers del llamador
+// (de al menos 15 y 9 bytes). Devuelve false si hubo un error (ver LastError).
+//
+//export CaeRequestBuf
+func CaeRequestBuf(handle int64, cabRequestCchar, detRequestCchar *C.char, caeBuf *C.char, caeSize int32, caeFchVtoBuf *C.char, caeFchVtoSize int32) bool {
+	cae, caeFchVto, ok := requestCAE(handle, C.GoString(cabRequestCchar), C.GoString(detRequestCchar))
+	copyToBuffer(cae, caeBuf, caeSize)
+	copyToBuffer(caeFchVto, caeFchVtoBuf, caeFchVtoSize)
+	return ok
+}
+
+// LastError devuelve el último error del handle. Con un handle inexistente indica que no existe;
+// CreateWSFEService e InspectCert informan sus errores en el buffer que reciben.
+//
 //export LastError
-func LastError() *C.char {
-	return C.CString(lastError)
+func LastError(handle int64) *C.char {
+	return C.CString(lastError(handle))
+}
+
+// LastErrorBuf es como LastError pero escribe el error en el buffer del llamador
+//
+//export LastErrorBuf
+func LastErrorBuf(handle int64, buf *C.char, size int32) int32 {
+	return copyToBuffer(lastError(handle), buf, size)
 }
 
 func main() {
-	ret := CreateWSFEService("certs", 20285142084)
-	if !ret {
-		log.Println(lastError)
+	certsPath := C.CString("certs")
+	defer FreeString(certsPath)
+
+	handle, err := createService(C.GoString(certsPath), 20285142084, int32(wsafip.PRODUCTION))
+	if err != nil {
+		log.Println(err)
 		return
 	}
+	defer DestroyService(handle)
 
 	request := C.CString(`{"cbteTipo":1,"cuit":20285142084,"pos":6}`)
-	nroUltimoComp := GetUltimoComp(request)
+	defer FreeString(request)
+	nroUltimoComp := GetUltimoComp(handle, request)
 	log.Println(nroUltimoComp)
 
 	/*
@@ -1,9 +1,12 @@
 module github.com/sisuani/gowsfe
 
-go 1.16
+go 1.19
 
 require (
 	github.com/hooklift/gowsdl v0.5.0
-	go.mozilla.org/pkcs7 v0.0.0-20200128120323-432b2356ecb1
-	golang.org/x/crypto v0.0.0-20210616213533-5ff15b29337e
+	github.com/smallstep/pkcs7 v0.2.3
+	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78
+	software.sslmate.com/src/go-pkcs12 v0.4.0
 )
+
+require golang.org/x/crypto v0.22.0 // indirect
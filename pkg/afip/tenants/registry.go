@@ -0,0 +1,172 @@
+package tenants
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sisuani/gowsfe/pkg/afip/wsafip"
+	"github.com/sisuani/gowsfe/pkg/afip/wsfe"
+	"github.com/sisuani/gowsfe/pkg/certs"
+)
+
+// Option configura opciones de Registry en NewRegistry
+type Option func(*Registry)
+
+// WithWSAAOptions agrega opciones a cada wsafip.Service creado por el registry
+func WithWSAAOptions(opts ...wsafip.Option) Option {
+	return func(r *Registry) {
+		r.wsaaOptions = append(r.wsaaOptions, opts...)
+	}
+}
+
+// WithWSFEOptions agrega opciones a cada wsfe.Service creado por el registry
+func WithWSFEOptions(opts ...wsfe.Option) Option {
+	return func(r *Registry) {
+		r.wsfeOptions = append(r.wsfeOptions, opts...)
+	}
+}
+
+// WithTicketDir persiste los tickets de todos los contribuyentes en el directorio indicado
+func WithTicketDir(dir string) Option {
+	return func(r *Registry) {
+		r.store = wsafip.NewFileTicketStore(dir)
+	}
+}
+
+// tenant es un contribuyente con certificado propio
+type tenant struct {
+	cert string
+	key  string
+	afip *wsafip.Service
+	wsfe *wsfe.Service
+}
+
+// Registry administra las credenciales de varios contribuyentes (cuit) en un mismo proceso.
+// Cada certificado tiene sus propios tickets y un cuit puede facturar con el certificado de
+// otro contribuyente que lo representa. Es seguro para uso concurrente.
+type Registry struct {
+	environment wsafip.Environment
+	store       wsafip.TicketStore
+	wsaaOptions []wsafip.Option
+	wsfeOptions []wsfe.Option
+
+	mu            sync.Mutex
+	tenants       map[int64]*tenant
+	representados map[int64]int64
+}
+
+// NewRegistry crea un registry de contribuyentes para el environment indicado
+func NewRegistry(environment wsafip.Environment, opts ...Option) *Registry {
+	r := &Registry{
+		environment:   environment,
+		store:         wsafip.NewMemoryTicketStore(),
+		tenants:       make(map[int64]*tenant),
+		representados: make(map[int64]int64),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Registry) wsfeEnvironment() wsfe.Environment {
+	if r.environment == wsafip.PRODUCTION {
+		return wsfe.PRODUCTION
+	}
+	return wsfe.TESTING
+}
+
+// Register agrega (o reemplaza) un contribuyente con su certificado y clave privada.
+// El certificado y la clave se validan al registrarlos (correspondencia, vigencia y CUIT).
+// Registrar nuevamente los mismos archivos conserva los servicios (y sus tickets) existentes.
+func (r *Registry) Register(cuit int64, cert, key string) error {
+	if err := certs.ValidateKeyPair(cert, key, cuit); err != nil {
+		return fmt.Errorf("Register %d: %s", cuit, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.representados, cuit)
+	if t, ok := r.tenants[cuit]; ok && t.cert == cert && t.key == key {
+		return nil
+	}
+
+	// Todos los servicios comparten el store: la clave del ticket incluye el hash del certificado,
+	// por lo que un servicio recreado recupera el ticket vigente en lugar de chocar con
+	// coe.alreadyAuthenticated
+	wsaaOptions := append([]wsafip.Option{wsafip.WithTicketStore(r.store)}, r.wsaaOptions...)
+	afip := wsafip.NewService(r.environment, cert, key, wsaaOptions...)
+	wsfeOptions := append([]wsfe.Option{wsfe.WithCredentialsProvider(wsfe.NewAFIPCredentials(afip))}, r.wsfeOptions...)
+
+	r.tenants[cuit] = &tenant{
+		cert: cert,
+		key:  key,
+		afip: afip,
+		wsfe: wsfe.NewService(r.wsfeEnvironment(), "", "", wsfeOptions...),
+	}
+	return nil
+}
+
+// RegisterRepresentado permite que cuit facture con el certificado de representante, que debe
+// estar registrado y tener delegado el servicio en AFIP.
+func (r *Registry) RegisterRepresentado(cuit, representante int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tenants[representante]; !ok {
+		return fmt.Errorf("RegisterRepresentado: el representante %d no está registrado", representante)
+	}
+
+	r.representados[cuit] = representante
+	return nil
+}
+
+// Remove quita un contribuyente del registry junto con sus representados
+func (r *Registry) Remove(cuit int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.tenants, cuit)
+	delete(r.representados, cuit)
+	for representado, representante := range r.representados {
+		if representante == cuit {
+			delete(r.representados, representado)
+		}
+	}
+}
+
+func (r *Registry) get(cuit int64) (*tenant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	owner := cuit
+	if representante, ok := r.representados[cuit]; ok {
+		owner = representante
+	}
+
+	t, ok := r.tenants[owner]
+	if !ok {
+		return nil, fmt.Errorf("el cuit %d no está registrado", cuit)
+	}
+	return t, nil
+}
+
+// WSAA devuelve el servicio de autenticación del certificado con el que factura cuit
+func (r *Registry) WSAA(cuit int64) (*wsafip.Service, error) {
+	t, err := r.get(cuit)
+	if err != nil {
+		return nil, err
+	}
+	return t.afip, nil
+}
+
+// WSFE devuelve el servicio de factura electrónica listo para facturar en nombre de cuit.
+// Los tickets se obtienen y renuevan automáticamente con el certificado correspondiente.
+func (r *Registry) WSFE(cuit int64) (*wsfe.Service, error) {
+	t, err := r.get(cuit)
+	if err != nil {
+		return nil, err
+	}
+	return t.wsfe, nil
+}
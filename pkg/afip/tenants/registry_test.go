@@ -0,0 +1,162 @@
+package tenants
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sisuani/gowsfe/pkg/afip/wsafip"
+	"github.com/sisuani/gowsfe/pkg/certs"
+)
+
+// testKeyPair genera en dir un certificado autofirmado para cuit y su clave
+func testKeyPair(t *testing.T, dir string, cuit int64) (string, string) {
+	t.Helper()
+
+	certFile, keyFile := filepath.Join(dir, "cert.crt"), filepath.Join(dir, "cert.key")
+	key, err := certs.GenerateKey(keyFile, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test", SerialNumber: fmt.Sprintf("CUIT %d", cuit)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestRegistryRepresentados(t *testing.T) {
+	r := NewRegistry(wsafip.TESTING)
+	cert, key := testKeyPair(t, t.TempDir(), 20111111112)
+	if err := r.Register(20111111112, cert, key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.RegisterRepresentado(30500010912, 27000000006); err == nil {
+		t.Error("RegisterRepresentado() accepted a representante that is not registered")
+	}
+	if err := r.RegisterRepresentado(30500010912, 20111111112); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cuit    int64
+		wantErr bool
+	}{
+		{20111111112, false},
+		{30500010912, false},
+		{27000000006, true},
+	}
+	for _, tt := range tests {
+		got, err := r.get(tt.cuit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("get(%d) error = %v, wantErr %v", tt.cuit, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != r.tenants[20111111112] {
+			t.Errorf("get(%d) did not return the representante", tt.cuit)
+		}
+	}
+
+	r.Remove(20111111112)
+	for _, cuit := range []int64{20111111112, 30500010912} {
+		if _, err := r.get(cuit); err == nil {
+			t.Errorf("get(%d) after Remove() did not fail", cuit)
+		}
+	}
+	if len(r.representados) != 0 {
+		t.Errorf("Remove() kept the representados %v", r.representados)
+	}
+}
+
+func TestRegistryRegisterAgain(t *testing.T) {
+	var logins int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&logins, 1)
+		now := time.Now()
+		ticket := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><loginTicketResponse version="1.0"><header><source>CN=wsaahomo</source><destination>SERIALNUMBER=CUIT 20111111112, CN=test</destination><uniqueId>1</uniqueId><generationTime>%s</generationTime><expirationTime>%s</expirationTime></header><credentials><token>T%d</token><sign>SIGN</sign></credentials></loginTicketResponse>`,
+			now.Format(time.RFC3339), now.Add(12*time.Hour).Format(time.RFC3339), n)
+		fmt.Fprintf(w, `<?xml version="1.0"?><soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><loginCmsResponse xmlns="http://wsaa.view.sua.dvadac.desein.afip.gov"><loginCmsReturn>%s</loginCmsReturn></loginCmsResponse></soapenv:Body></soapenv:Envelope>`, html.EscapeString(ticket))
+	}))
+	defer server.Close()
+
+	r := NewRegistry(wsafip.PRODUCTION, WithWSAAOptions(wsafip.WithURL(server.URL)))
+	dir := t.TempDir()
+	cert, key := testKeyPair(t, dir, 20111111112)
+
+	// El mismo certificado copiado en otra ruta, como cuando se mueven los archivos del contribuyente
+	otherCert, otherKey := filepath.Join(dir, "other.crt"), filepath.Join(dir, "other.key")
+	for src, dst := range map[string]string{cert: otherCert, key: otherKey} {
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(dst, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ticket := func() string {
+		t.Helper()
+		wsaa, err := r.WSAA(20111111112)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ticket, err := wsaa.Ticket(context.Background(), wsafip.ServiceWSFE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ticket.Token
+	}
+
+	if err := r.Register(20111111112, cert, key); err != nil {
+		t.Fatal(err)
+	}
+	first := r.tenants[20111111112]
+	if token := ticket(); token != "T1" {
+		t.Fatalf("Ticket() = %s, want T1", token)
+	}
+
+	if err := r.Register(20111111112, cert, key); err != nil {
+		t.Fatal(err)
+	}
+	if r.tenants[20111111112] != first {
+		t.Error("Register() with the same files replaced the services")
+	}
+
+	// Otros archivos crean un servicio nuevo que recupera el ticket del store compartido
+	if err := r.Register(20111111112, otherCert, otherKey); err != nil {
+		t.Fatal(err)
+	}
+	if r.tenants[20111111112] == first {
+		t.Error("Register() with other files kept the services")
+	}
+	if token := ticket(); token != "T1" {
+		t.Errorf("Ticket() after Register() = %s, want the stored T1", token)
+	}
+	if n := atomic.LoadInt32(&logins); n != 1 {
+		t.Errorf("WSAA logins = %d, want 1", n)
+	}
+}
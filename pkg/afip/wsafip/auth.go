@@ -0,0 +1,70 @@
+package wsafip
+
+import (
+	"context"
+	"time"
+)
+
+// Nombres de servicios de AFIP en WSAA
+const (
+	ServiceWSFE      = "wsfe"
+	ServiceWSFEX     = "wsfex"
+	ServiceWSMTXCA   = "wsmtxca"
+	ServiceWSCT      = "wsct"
+	ServicePadronA13 = "ws_sr_padron_a13"
+)
+
+// Auth son las credenciales de acceso a un servicio de AFIP
+type Auth struct {
+	Token          string
+	Sign           string
+	ExpirationTime time.Time
+}
+
+// AuthProvider provee credenciales vigentes para cualquier servicio de AFIP, obteniéndolas y
+// renovándolas por servicio. Service implementa AuthProvider.
+type AuthProvider interface {
+	Auth(ctx context.Context, serviceName string) (*Auth, error)
+	RenewTicket(serviceName, rejectedToken string)
+}
+
+// Auth devuelve las credenciales vigentes del servicio, solicitando un nuevo ticket si es necesario
+func (s *Service) Auth(ctx context.Context, serviceName string) (*Auth, error) {
+	ticket, err := s.Ticket(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Auth{Token: ticket.Token, Sign: ticket.Sign, ExpirationTime: ticket.ExpirationTime}, nil
+}
+
+// ServiceAuth vincula un AuthProvider a un servicio de AFIP para que los clientes de cada
+// servicio obtengan token y sign sin conocer WSAA
+type ServiceAuth struct {
+	provider    AuthProvider
+	serviceName string
+}
+
+// ForService devuelve las credenciales del servicio indicado obtenidas desde provider
+func ForService(provider AuthProvider, serviceName string) *ServiceAuth {
+	return &ServiceAuth{provider: provider, serviceName: serviceName}
+}
+
+// ServiceName devuelve el nombre del servicio
+func (a *ServiceAuth) ServiceName() string {
+	return a.serviceName
+}
+
+// Credentials devuelve token y sign vigentes. Si rejectedToken no es vacío (el servicio de negocio
+// rechazó ese token) y sigue siendo el vigente, se descarta y se solicita uno nuevo.
+func (a *ServiceAuth) Credentials(ctx context.Context, rejectedToken string) (string, string, error) {
+	if rejectedToken != "" {
+		a.provider.RenewTicket(a.serviceName, rejectedToken)
+	}
+
+	auth, err := a.provider.Auth(ctx, a.serviceName)
+	if err != nil {
+		return "", "", err
+	}
+	return auth.Token, auth.Sign, nil
+}
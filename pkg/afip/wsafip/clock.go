@@ -0,0 +1,83 @@
+package wsafip
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var lastUniqueID uint32
+
+// nextUniqueID devuelve un uniqueId creciente basado en la hora, único dentro del proceso
+func nextUniqueID(now time.Time) uint32 {
+	for {
+		last := atomic.LoadUint32(&lastUniqueID)
+		id := uint32(now.Unix())
+		if id <= last {
+			id = last + 1
+		}
+		if atomic.CompareAndSwapUint32(&lastUniqueID, last, id) {
+			return id
+		}
+	}
+}
+
+// now devuelve la hora local corregida con el offset del reloj
+func (s *Service) now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Now().Add(s.clockOffset)
+}
+
+// ClockOffset devuelve la corrección aplicada a la hora local
+func (s *Service) ClockOffset() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clockOffset
+}
+
+// SyncClock calcula la diferencia entre la hora de WSAA (header Date de la respuesta HTTP) y la
+// hora local, y la utiliza para generar los login ticket requests y verificar vencimientos.
+func (s *Service) SyncClock(ctx context.Context) (time.Duration, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	client := s.httpClient
+	if client == nil {
+		client = &http.Client{Transport: &http.Transport{TLSClientConfig: s.tlsConfig}}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.urlWsaa, nil)
+	if err != nil {
+		return 0, fmt.Errorf("SyncClock: %s", err)
+	}
+	req = req.WithContext(ctx)
+
+	sent := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("SyncClock: %s", err)
+	}
+	res.Body.Close()
+	received := time.Now()
+
+	serverTime, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("SyncClock: header Date inválido. %s", err)
+	}
+
+	// Se toma el punto medio del viaje como hora local de la respuesta
+	local := sent.Add(received.Sub(sent) / 2)
+	offset := serverTime.Sub(local).Truncate(time.Second)
+
+	s.mu.Lock()
+	s.clockOffset = offset
+	s.mu.Unlock()
+
+	return offset, nil
+}
@@ -0,0 +1,242 @@
+package wsafip
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sisuani/gowsfe/pkg/certs"
+)
+
+// CredentialSource provee el certificado y la clave con los que se firma el login ticket request.
+// Las implementaciones deben ser seguras para uso concurrente.
+type CredentialSource interface {
+	KeyPair() (*x509.Certificate, crypto.Signer, error)
+}
+
+// ChainCredentialSource es un CredentialSource que además provee los certificados intermedios que se
+// incluyen en el CMS (ver certs.WithChain)
+type ChainCredentialSource interface {
+	CredentialSource
+	Chain() ([]*x509.Certificate, error)
+}
+
+// WithCredentialSource obtiene el certificado y la clave desde source. Los parámetros cert y key
+// de NewService no se utilizan. Por defecto se usa NewFileCredentialSource(cert, key, true).
+func WithCredentialSource(source CredentialSource) Option {
+	return func(s *Service) {
+		s.credentials = source
+	}
+}
+
+// FileCredentialSource lee el certificado y la clave de archivos PEM. Se interpretan una sola vez;
+// si reload es true se vuelven a leer cuando cambia la fecha de modificación de alguno de los archivos.
+type FileCredentialSource struct {
+	certFile string
+	keyFile  string
+	password []byte
+	reload   bool
+
+	mu          sync.Mutex
+	certificate *x509.Certificate
+	signer      crypto.Signer
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// NewFileCredentialSource crea un CredentialSource a partir de los archivos de certificado y clave
+func NewFileCredentialSource(certFile, keyFile string, reload bool) *FileCredentialSource {
+	return &FileCredentialSource{certFile: certFile, keyFile: keyFile, reload: reload}
+}
+
+// NewEncryptedFileCredentialSource es como NewFileCredentialSource pero descifra la clave con password
+// (ver certs.LoadEncryptedX509KeyPair)
+func NewEncryptedFileCredentialSource(certFile, keyFile, password string, reload bool) *FileCredentialSource {
+	return &FileCredentialSource{certFile: certFile, keyFile: keyFile, password: []byte(password), reload: reload}
+}
+
+func (f *FileCredentialSource) KeyPair() (*x509.Certificate, crypto.Signer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.signer != nil && !f.reload {
+		return f.certificate, f.signer, nil
+	}
+
+	var certModTime, keyModTime time.Time
+	if f.reload {
+		certInfo, err := os.Stat(f.certFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("LoadX509KeyPair: crt file not found: %s", err)
+		}
+		keyInfo, err := os.Stat(f.keyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("LoadX509KeyPair: key file not found: %s", err)
+		}
+		certModTime, keyModTime = certInfo.ModTime(), keyInfo.ModTime()
+
+		if f.signer != nil && certModTime.Equal(f.certModTime) && keyModTime.Equal(f.keyModTime) {
+			return f.certificate, f.signer, nil
+		}
+	}
+
+	var certificate *x509.Certificate
+	var signer crypto.Signer
+	var err error
+	if f.password != nil {
+		certificate, signer, err = certs.LoadEncryptedX509KeyPair(f.certFile, f.keyFile, string(f.password))
+	} else {
+		certificate, signer, err = certs.LoadX509KeyPair(f.certFile, f.keyFile)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f.certificate, f.signer = certificate, signer
+	f.certModTime, f.keyModTime = certModTime, keyModTime
+	return certificate, signer, nil
+}
+
+// PKCS12CredentialSource lee el certificado, la clave y la cadena de un archivo PKCS#12 (.p12/.pfx).
+// Igual que FileCredentialSource se interpreta una sola vez y, si reload es true, se vuelve a leer
+// cuando cambia su fecha de modificación.
+type PKCS12CredentialSource struct {
+	file     string
+	password string
+	reload   bool
+
+	mu          sync.Mutex
+	certificate *x509.Certificate
+	signer      crypto.Signer
+	chain       []*x509.Certificate
+	modTime     time.Time
+}
+
+// NewPKCS12CredentialSource crea un CredentialSource a partir de un archivo PKCS#12 protegido con password
+func NewPKCS12CredentialSource(file, password string, reload bool) *PKCS12CredentialSource {
+	return &PKCS12CredentialSource{file: file, password: password, reload: reload}
+}
+
+func (p *PKCS12CredentialSource) KeyPair() (*x509.Certificate, crypto.Signer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.load(); err != nil {
+		return nil, nil, err
+	}
+	return p.certificate, p.signer, nil
+}
+
+// Chain devuelve los demás certificados del archivo, que se envían junto al certificado firmante
+func (p *PKCS12CredentialSource) Chain() ([]*x509.Certificate, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p.chain, nil
+}
+
+func (p *PKCS12CredentialSource) load() error {
+	if p.signer != nil && !p.reload {
+		return nil
+	}
+
+	var modTime time.Time
+	if p.reload {
+		info, err := os.Stat(p.file)
+		if err != nil {
+			return fmt.Errorf("LoadPKCS12: file not found: %s", err)
+		}
+		modTime = info.ModTime()
+
+		if p.signer != nil && modTime.Equal(p.modTime) {
+			return nil
+		}
+	}
+
+	certificate, signer, chain, err := certs.LoadPKCS12(p.file, p.password)
+	if err != nil {
+		return err
+	}
+
+	p.certificate, p.signer, p.chain = certificate, signer, chain
+	p.modTime = modTime
+	return nil
+}
+
+// MemoryCredentialSource es un certificado y una clave ya cargados en memoria
+type MemoryCredentialSource struct {
+	certificate *x509.Certificate
+	signer      crypto.Signer
+}
+
+// NewMemoryCredentialSource interpreta el certificado y la clave en formato PEM (por ejemplo
+// obtenidos de un gestor de secretos) sin escribirlos a disco
+func NewMemoryCredentialSource(certPEM, keyPEM []byte) (*MemoryCredentialSource, error) {
+	certificate, signer, err := certs.ParseX509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryCredentialSource{certificate: certificate, signer: signer}, nil
+}
+
+func (m *MemoryCredentialSource) KeyPair() (*x509.Certificate, crypto.Signer, error) {
+	return m.certificate, m.signer, nil
+}
+
+// EnvCredentialSource lee el certificado y la clave de variables de entorno. Cada variable puede
+// contener el PEM o el PEM codificado en base64 (útil cuando no se admiten saltos de línea).
+// Las variables se interpretan una sola vez.
+type EnvCredentialSource struct {
+	certVar string
+	keyVar  string
+
+	once   sync.Once
+	memory *MemoryCredentialSource
+	err    error
+}
+
+// NewEnvCredentialSource crea un CredentialSource a partir de las variables de entorno indicadas
+func NewEnvCredentialSource(certVar, keyVar string) *EnvCredentialSource {
+	return &EnvCredentialSource{certVar: certVar, keyVar: keyVar}
+}
+
+func (e *EnvCredentialSource) KeyPair() (*x509.Certificate, crypto.Signer, error) {
+	e.once.Do(func() {
+		var certPEM, keyPEM []byte
+		if certPEM, e.err = envPEM(e.certVar); e.err != nil {
+			return
+		}
+		if keyPEM, e.err = envPEM(e.keyVar); e.err != nil {
+			return
+		}
+		e.memory, e.err = NewMemoryCredentialSource(certPEM, keyPEM)
+	})
+	if e.err != nil {
+		return nil, nil, e.err
+	}
+	return e.memory.KeyPair()
+}
+
+func envPEM(name string) ([]byte, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	if strings.HasPrefix(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("environment variable %s is neither PEM nor base64: %s", name, err)
+	}
+	return data, nil
+}
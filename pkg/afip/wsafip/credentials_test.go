@@ -0,0 +1,88 @@
+package wsafip
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sisuani/gowsfe/pkg/certs"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestCredentialSourcesCache(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := testKeyPair(t, dir)
+	crt, key, err := certs.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	// Cifrado PEM tradicional, como openssl rsa -aes256
+	encrypted, err := x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte("secreto"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedFile := filepath.Join(dir, "encrypted.key")
+	if err := ioutil.WriteFile(encryptedFile, pem.EncodeToMemory(encrypted), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pfx, err := pkcs12.Modern.Encode(key, crt, nil, "secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfxFile := filepath.Join(dir, "cert.pfx")
+	if err := ioutil.WriteFile(pfxFile, pfx, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		source CredentialSource
+		files  []string
+	}{
+		{"file", NewFileCredentialSource(certFile, keyFile, true), []string{certFile, keyFile}},
+		{"encrypted file", NewEncryptedFileCredentialSource(certFile, encryptedFile, "secreto", true), []string{certFile, encryptedFile}},
+		{"PKCS#12", NewPKCS12CredentialSource(pfxFile, "secreto", true), []string{pfxFile}},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, first, err := tt.source.KeyPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, second, err := tt.source.KeyPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first != second {
+				t.Errorf("KeyPair() parsed the files again although they did not change")
+			}
+
+			modTime := time.Now().Add(time.Duration(i+1) * time.Minute)
+			for _, file := range tt.files {
+				if err := os.Chtimes(file, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, reloaded, err := tt.source.KeyPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reloaded == first {
+				t.Errorf("KeyPair() did not reload the modified files")
+			}
+		})
+	}
+}
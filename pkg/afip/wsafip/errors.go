@@ -0,0 +1,89 @@
+package wsafip
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hooklift/gowsdl/soap"
+)
+
+// Errores de WSAA clasificados según el faultcode devuelto
+var (
+	ErrAlreadyAuthenticated = errors.New("el CEE ya posee un TA válido para el servicio")
+	ErrCertExpired          = errors.New("certificado vencido")
+	ErrCertUntrusted        = errors.New("certificado no emitido por AFIP")
+	ErrInvalidSignature     = errors.New("firma inválida")
+	ErrClockSkew            = errors.New("generationTime o expirationTime inválidos, verificar la hora del equipo")
+	ErrServiceNotAuthorized = errors.New("el certificado no tiene acceso al servicio")
+	ErrServiceUnavailable   = errors.New("el servicio solicitado no está disponible")
+	ErrServiceNotFound      = errors.New("el servicio solicitado no existe")
+)
+
+// faultKinds asocia los faultcode de WSAA (sin prefijo de namespace) con los errores tipados
+var faultKinds = map[string]error{
+	"coe.alreadyAuthenticated":   ErrAlreadyAuthenticated,
+	"cms.cert.expired":           ErrCertExpired,
+	"cms.cert.untrusted":         ErrCertUntrusted,
+	"cms.cert.invalid":           ErrCertUntrusted,
+	"cms.sign.invalid":           ErrInvalidSignature,
+	"xml.generationTime.invalid": ErrClockSkew,
+	"xml.expirationTime.expired": ErrClockSkew,
+	"xml.expirationTime.invalid": ErrClockSkew,
+	"coe.notAuthorized":          ErrServiceNotAuthorized,
+	"wsn.unavailable":            ErrServiceUnavailable,
+	"wsn.notFound":               ErrServiceNotFound,
+}
+
+// LoginError es un SOAP fault devuelto por WSAA. Se puede clasificar con errors.Is
+// contra ErrAlreadyAuthenticated, ErrCertExpired, etc.
+type LoginError struct {
+	Code   string
+	String string
+	kind   error
+}
+
+func (e *LoginError) Error() string {
+	return fmt.Sprintf("GetLoginTicket: %s (%s)", e.String, e.Code)
+}
+
+func (e *LoginError) Unwrap() error {
+	return e.kind
+}
+
+type faultEnvelope struct {
+	Body struct {
+		Fault *struct {
+			Code   string `xml:"faultcode"`
+			String string `xml:"faultstring"`
+		} `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+func newLoginError(code, str string) *LoginError {
+	code = strings.TrimSpace(code)
+	if i := strings.LastIndex(code, ":"); i >= 0 {
+		code = code[i+1:]
+	}
+	return &LoginError{Code: code, String: strings.TrimSpace(str), kind: faultKinds[code]}
+}
+
+// parseLoginFault convierte los SOAP fault de WSAA en *LoginError. WSAA responde los fault
+// con HTTP 500, por lo que también se analiza el cuerpo de soap.HTTPError.
+func parseLoginFault(err error) error {
+	var fault *soap.SOAPFault
+	if errors.As(err, &fault) {
+		return newLoginError(fault.Code, fault.String)
+	}
+
+	var httpErr *soap.HTTPError
+	if errors.As(err, &httpErr) {
+		envelope := faultEnvelope{}
+		if xml.Unmarshal(httpErr.ResponseBody, &envelope) == nil && envelope.Body.Fault != nil {
+			return newLoginError(envelope.Body.Fault.Code, envelope.Body.Fault.String)
+		}
+	}
+
+	return fmt.Errorf("GetLoginTicket: %w", err)
+}
@@ -0,0 +1,68 @@
+package wsafip
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hooklift/gowsdl/soap"
+)
+
+func TestNewLoginError(t *testing.T) {
+	tests := []struct {
+		code     string
+		wantCode string
+		want     error
+	}{
+		{"ns1:coe.alreadyAuthenticated", "coe.alreadyAuthenticated", ErrAlreadyAuthenticated},
+		{"cms.cert.expired", "cms.cert.expired", ErrCertExpired},
+		{"ns1:cms.cert.untrusted", "cms.cert.untrusted", ErrCertUntrusted},
+		{"ns1:cms.cert.invalid", "cms.cert.invalid", ErrCertUntrusted},
+		{"ns1:cms.sign.invalid", "cms.sign.invalid", ErrInvalidSignature},
+		{"ns1:xml.generationTime.invalid", "xml.generationTime.invalid", ErrClockSkew},
+		{"ns1:xml.expirationTime.expired", "xml.expirationTime.expired", ErrClockSkew},
+		{"ns1:coe.notAuthorized", "coe.notAuthorized", ErrServiceNotAuthorized},
+		{"ns1:wsn.unavailable", "wsn.unavailable", ErrServiceUnavailable},
+		{"ns1:wsn.notFound", "wsn.notFound", ErrServiceNotFound},
+		{" ns1:xml.bad ", "xml.bad", nil},
+	}
+
+	kinds := []error{ErrAlreadyAuthenticated, ErrCertExpired, ErrCertUntrusted, ErrInvalidSignature, ErrClockSkew, ErrServiceNotAuthorized, ErrServiceUnavailable, ErrServiceNotFound}
+	for _, tt := range tests {
+		err := newLoginError(tt.code, " mensaje ")
+		if err.Code != tt.wantCode || err.String != "mensaje" {
+			t.Errorf("newLoginError(%q) = %q, %q", tt.code, err.Code, err.String)
+		}
+		for _, kind := range kinds {
+			if got := errors.Is(err, kind); got != (kind == tt.want) {
+				t.Errorf("errors.Is(newLoginError(%q), %v) = %v", tt.code, kind, got)
+			}
+		}
+	}
+}
+
+func TestParseLoginFault(t *testing.T) {
+	envelope := []byte(`<?xml version="1.0"?><soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><soapenv:Fault><faultcode>ns1:cms.cert.expired</faultcode><faultstring>Certificado expirado</faultstring></soapenv:Fault></soapenv:Body></soapenv:Envelope>`)
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"soap fault", &soap.SOAPFault{Code: "ns1:coe.alreadyAuthenticated", String: "ya autenticado"}, ErrAlreadyAuthenticated},
+		{"http 500", &soap.HTTPError{StatusCode: 500, ResponseBody: envelope}, ErrCertExpired},
+		{"http sin fault", &soap.HTTPError{StatusCode: 502, ResponseBody: []byte("Bad Gateway")}, nil},
+		{"otro error", errors.New("connection refused"), nil},
+	}
+
+	for _, tt := range tests {
+		err := parseLoginFault(tt.err)
+		var loginErr *LoginError
+		if isLoginErr := errors.As(err, &loginErr); isLoginErr != (tt.want != nil) {
+			t.Errorf("%s: parseLoginFault() = %v", tt.name, err)
+			continue
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: parseLoginFault() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
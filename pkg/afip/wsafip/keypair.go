@@ -0,0 +1,35 @@
+package wsafip
+
+import (
+	"crypto"
+	"crypto/x509"
+)
+
+// KeyPairLoader obtiene el certificado y la clave con los que se firma el login ticket request.
+// Se invoca en cada login, por lo que los cambios en los archivos se toman sin reiniciar el Service.
+type KeyPairLoader func() (*x509.Certificate, crypto.Signer, error)
+
+// KeyPair implementa CredentialSource
+func (l KeyPairLoader) KeyPair() (*x509.Certificate, crypto.Signer, error) {
+	return l()
+}
+
+// WithKeyPairLoader obtiene el certificado y la clave desde loader en lugar de los archivos cert y key
+func WithKeyPairLoader(loader KeyPairLoader) Option {
+	return WithCredentialSource(loader)
+}
+
+// WithPKCS12 obtiene el certificado y la clave de un archivo PKCS#12 (.p12/.pfx) protegido con password.
+// Los certificados intermedios del archivo se incluyen en el CMS. Los parámetros cert y key de
+// NewService no se utilizan.
+func WithPKCS12(file, password string) Option {
+	return WithCredentialSource(NewPKCS12CredentialSource(file, password, true))
+}
+
+// WithKeyPassword descifra con password la clave PEM indicada en NewService. Como con los archivos sin
+// cifrar, la clave se descifra una sola vez y se vuelve a leer solo si cambian los archivos.
+func WithKeyPassword(password string) Option {
+	return func(s *Service) {
+		s.credentials = NewEncryptedFileCredentialSource(s.cert, s.key, password, true)
+	}
+}
@@ -0,0 +1,97 @@
+package wsafip
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"github.com/sisuani/gowsfe/pkg/certs"
+)
+
+// Option configura opciones de Service en NewService
+type Option func(*Service)
+
+// WithURL reemplaza la URL de WSAA (por ejemplo para un mock local o un proxy)
+func WithURL(url string) Option {
+	return func(s *Service) {
+		s.urlWsaa = url
+	}
+}
+
+// WithHTTPClient utiliza el cliente HTTP indicado para las llamadas a WSAA.
+// Las opciones WithTLSConfig y WithTransport no se aplican a un cliente propio.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Service) {
+		s.httpClient = client
+	}
+}
+
+// WithTransport utiliza el transport HTTP indicado para las llamadas a WSAA
+func WithTransport(transport http.RoundTripper) Option {
+	return func(s *Service) {
+		s.httpClient = &http.Client{Transport: transport}
+	}
+}
+
+// WithTLSConfig establece la configuración TLS de la conexión con WSAA
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(s *Service) {
+		s.tlsConfig = tlsConfig
+	}
+}
+
+// WithRenewMargin intenta renovar el ticket cuando falta menos que margin para su vencimiento.
+// WSAA puede rechazar el login si el ticket anterior sigue vigente (coe.alreadyAuthenticated); en ese
+// caso se sigue usando el ticket actual sin volver a intentar hasta que venza. El margen por defecto es 0.
+func WithRenewMargin(margin time.Duration) Option {
+	return func(s *Service) {
+		s.renewMargin = margin
+	}
+}
+
+// WithRequestTTL establece la ventana de validez del login ticket request:
+// generationTime = ahora - ttl y expirationTime = ahora + ttl
+func WithRequestTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.requestTTL = ttl
+	}
+}
+
+// WithClockOffset corrige la hora local sumándole offset (hora del servidor - hora local)
+func WithClockOffset(offset time.Duration) Option {
+	return func(s *Service) {
+		s.clockOffset = offset
+	}
+}
+
+// WithCMSOptions configura la firma del login ticket request (digest, certificados intermedios)
+func WithCMSOptions(opts ...certs.CMSOption) Option {
+	return func(s *Service) {
+		s.cmsOptions = opts
+	}
+}
+
+// WithSigner firma el login ticket request con el certificado y la clave indicados en lugar de leer
+// los archivos cert y key. Permite utilizar claves que no se pueden exportar (HSM, almacén del sistema).
+func WithSigner(certificate *x509.Certificate, signer crypto.Signer) Option {
+	return WithCredentialSource(&MemoryCredentialSource{certificate: certificate, signer: signer})
+}
+
+// WithAlreadyAuthenticatedRetry reintenta el login hasta retries veces, esperando wait entre
+// intentos, cuando WSAA responde que ya existe un ticket vigente y no hay uno anterior o
+// persistido para utilizar
+func WithAlreadyAuthenticatedRetry(retries int, wait time.Duration) Option {
+	return func(s *Service) {
+		s.alreadyAuthRetries = retries
+		s.alreadyAuthWait = wait
+	}
+}
+
+// WithTimeout establece el tiempo de espera por defecto de la llamada a WSAA (RequestTimeout)
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Service) {
+		s.timeout = timeout
+	}
+}
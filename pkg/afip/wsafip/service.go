@@ -1,9 +1,14 @@
 package wsafip
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/sisuani/gowsfe/pkg/certs"
@@ -13,6 +18,9 @@ import (
 
 const RequestTimeout = 60 * time.Second
 
+// RequestTTL es la ventana de validez por defecto del login ticket request (generationTime y expirationTime)
+const RequestTTL = 10 * time.Minute
+
 // URLWSAATesting ... wsdl de wsaa en environment de homolagación de afip
 const URLWSAATesting string = "https://wsaahomo.afip.gov.ar/ws/services/LoginCms?WSDL"
 
@@ -28,21 +36,44 @@ const (
 	PRODUCTION
 )
 
-// Service es la estructura global del paquete
+func (e Environment) String() string {
+	if e == PRODUCTION {
+		return "production"
+	}
+	return "testing"
+}
+
+// MarshalText representa el environment como texto (por ejemplo en JSON)
+func (e Environment) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// Service es la estructura global del paquete. Es seguro para uso concurrente.
 type Service struct {
 	environment Environment
 	key         string
 	cert        string
 	urlWsaa     string
 	tickets     map[string]*LoginTicketResponse
-}
-
-// LoginTicket es una estructura que representa un ticket de un servicio de afip
-type LoginTicket struct {
-	ServiceName    string
-	Token          string
-	Sign           string
-	ExpirationTime time.Time
+	store       TicketStore
+	certID      string
+	timeout     time.Duration
+	httpClient  *http.Client
+	tlsConfig   *tls.Config
+	renewMargin time.Duration
+	requestTTL  time.Duration
+	clockOffset time.Duration
+
+	alreadyAuthRetries int
+	alreadyAuthWait    time.Duration
+
+	credentials CredentialSource
+	cmsOptions  []certs.CMSOption
+
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+	renew map[string]string
+	kept  map[string]string
 }
 
 // HeaderLoginTicket es la cabecera de la estructura de request y response
@@ -76,7 +107,7 @@ type LoginTicketResponse struct {
 }
 
 // Create crea un objeto cliente para acceder a los servicios web de afip
-func NewService(environment Environment, cert, key string) *Service {
+func NewService(environment Environment, cert, key string, opts ...Option) *Service {
 	var url string
 	if environment == PRODUCTION {
 		url = URLWSAAProduction
@@ -84,92 +115,281 @@ func NewService(environment Environment, cert, key string) *Service {
 		url = URLWSAATesting
 	}
 
-	return &Service{environment: environment, urlWsaa: url, cert: cert, key: key, tickets: make(map[string]*LoginTicketResponse)}
+	s := &Service{environment: environment, urlWsaa: url, cert: cert, key: key, tickets: make(map[string]*LoginTicketResponse), locks: make(map[string]chan struct{}), renew: make(map[string]string), kept: make(map[string]string), store: NewMemoryTicketStore(), timeout: RequestTimeout, requestTTL: RequestTTL}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.credentials == nil {
+		s.credentials = NewFileCredentialSource(cert, key, true)
+	}
+
+	return s
+}
+
+func (s *Service) newSoapClient() *soap.Client {
+	if s.httpClient != nil {
+		return soap.NewClient(s.urlWsaa, soap.WithHTTPClient(s.httpClient))
+	}
+
+	// Igual que en wsfe.NewService, el timeout lo decide el deadline del contexto (ver login)
+	soapOptions := []soap.Option{soap.WithTimeout(s.timeout), soap.WithRequestTimeout(0)}
+	if s.tlsConfig != nil {
+		soapOptions = append(soapOptions, soap.WithTLS(s.tlsConfig))
+	}
+	return soap.NewClient(s.urlWsaa, soapOptions...)
 }
 
 // GetLoginTicket devuelve el ticket de acceso afip correspondiente al servicio pasado por parámetro.
 func (s *Service) GetLoginTicket(serviceName string) (token string, sign string, expiration string, err error) {
-	expired := true
+	return s.GetLoginTicketContext(context.Background(), serviceName)
+}
 
-	ticket, _ := s.tickets[serviceName]
+// GetLoginTicketContext es como GetLoginTicket pero permite cancelar la llamada a WSAA o fijar su timeout con ctx.
+// Si ctx no tiene deadline se aplica el timeout del servicio.
+func (s *Service) GetLoginTicketContext(ctx context.Context, serviceName string) (token string, sign string, expiration string, err error) {
+	ticket, err := s.loginTicket(ctx, serviceName)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return ticket.Credentials.Token, ticket.Credentials.Sign, ticket.Header.ExpirationTime, nil
+}
+
+// loginTicket devuelve el ticket vigente del servicio, solicitando uno nuevo a WSAA si es necesario
+func (s *Service) loginTicket(ctx context.Context, serviceName string) (*LoginTicketResponse, error) {
+	// Solo una goroutine por servicio puede verificar y renovar el ticket, las demás esperan
+	// y reutilizan el ticket obtenido (WSAA rechaza un segundo login con un ticket vigente)
+	lock := s.serviceLock(serviceName)
+	select {
+	case lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("GetLoginTicket: %s", ctx.Err())
+	}
+	defer func() { <-lock }()
+
+	key := s.ticketKey(serviceName)
+
+	// El pedido de renovación se consume en cualquier caso: si el login falla no se vuelve a
+	// descartar el ticket hasta que AFIP lo rechace nuevamente
+	s.mu.Lock()
+	ticket := s.tickets[serviceName]
+	rejected, renew := s.renew[serviceName]
+	delete(s.renew, serviceName)
+	kept := s.kept[serviceName]
+	s.mu.Unlock()
+
+	if renew && (ticket == nil || ticket.Credentials.Token != rejected) {
+		// El token rechazado ya fue reemplazado
+		rejected = ""
+	}
+	if ticket == nil || rejected != "" {
+		// Un ticket persistido ilegible o rechazado se descarta y se solicita uno nuevo
+		ticket = nil
+		if stored, _ := s.store.Load(key); stored != nil && stored.Credentials.Token != rejected {
+			ticket = stored
+		}
+	}
+
+	expired := true
 	if ticket != nil {
 		expTime, err := time.Parse(time.RFC3339, ticket.Header.ExpirationTime)
 		if err != nil {
-			return "", "", "", fmt.Errorf("GetLoginTicket: Error parseando fecha de expiración del ticket. %s", err)
+			return nil, fmt.Errorf("GetLoginTicket: Error parseando fecha de expiración del ticket. %s", err)
 		}
 
-		expired = time.Now().After(expTime)
+		now := s.now()
+		expired = now.After(expTime)
+		if !expired && now.Add(s.renewMargin).After(expTime) {
+			// WSAA ya rechazó la renovación anticipada de este ticket, se usa hasta que venza
+			expired = kept != ticket.Credentials.Token
+		}
 	}
 
+	var saveErr error
 	if expired {
-		expiration := time.Now().Add(10 * time.Minute)
-		generationTime := fmt.Sprintf("%s", time.Now().Add(-10*time.Minute).Format(time.RFC3339))
-		expirationTime := fmt.Sprintf("%s", expiration.Format(time.RFC3339))
-
-		// Armo estructura request
-		loginTicketRequest := LoginTicketRequest{
-			Version: "1.0",
-			Header: &HeaderLoginTicket{
-				UniqueID:       1,
-				GenerationTime: generationTime,
-				ExpirationTime: expirationTime,
-			},
-			Service: serviceName,
-		}
-
-		// Armo XML
-		loginTicketRequestXML, err := xml.MarshalIndent(loginTicketRequest, " ", "  ")
+		var fresh bool
+		var err error
+		ticket, fresh, err = s.obtainTicket(ctx, serviceName, key, ticket, rejected)
 		if err != nil {
-			return "", "", "", fmt.Errorf("GetLoginTicket: Error armando login ticket request XML. %s", err)
+			return nil, err
 		}
-		content := []byte(string(loginTicketRequestXML))
 
-		// Creo CMS (Cryptographic Message Syntax)
-		certificate, privateKey, err := certs.LoadX509KeyPair(s.cert, s.key)
-		if err != nil {
-			return "", "", "", fmt.Errorf("GetLoginTicket: %s", err)
+		// Almaceno ticket de respuesta (porque no se puede llamar nuevamente al servicio hasta dentro de 10 minutos,
+		// hay que seguir usando el ticket actual. El vencimiento de los ticket de afip suele ser de 12 horas)
+		if fresh {
+			saveErr = s.store.Save(key, ticket)
 		}
-		cms, err := certs.EncodeCMS(content, certificate, privateKey)
-		if err != nil {
-			return "", "", "", fmt.Errorf("GetLoginTicket: %s", err)
+
+		s.mu.Lock()
+		if fresh {
+			delete(s.kept, serviceName)
+		} else {
+			s.kept[serviceName] = ticket.Credentials.Token
 		}
+		s.mu.Unlock()
+	}
 
-		// Convierto CMS a base64
-		cmsBase64 := base64.StdEncoding.EncodeToString(cms)
+	s.mu.Lock()
+	s.tickets[serviceName] = ticket
+	s.mu.Unlock()
 
-		// Armo conexión SOAP y solicitud
-		soapClient := soap.NewClient(s.urlWsaa, soap.WithTimeout(RequestTimeout), soap.WithRequestTimeout(RequestTimeout))
-		login := NewLoginCMS(soapClient)
+	// El ticket queda en memoria aunque no se haya podido persistir
+	if saveErr != nil {
+		return nil, fmt.Errorf("GetLoginTicket: Error guardando ticket. %s", saveErr)
+	}
 
-		request := LoginCms{In0: cmsBase64}
+	return ticket, nil
+}
 
-		// Logeo solicitud
-		if s.environment == TESTING {
-			requestXML, _ := xml.MarshalIndent(request, " ", "  ")
-			fmt.Printf("REQUEST XML:\n%s\n\n", xml.Header+string(requestXML))
+// obtainTicket solicita un nuevo ticket a WSAA. Si WSAA indica que ya existe un ticket vigente
+// (ErrAlreadyAuthenticated) se utiliza el ticket anterior o el persistido si siguen vigentes, o se
+// reintenta según WithAlreadyAuthenticatedRetry. fresh indica si el ticket fue emitido por WSAA.
+func (s *Service) obtainTicket(ctx context.Context, serviceName, key string, previous *LoginTicketResponse, rejected string) (ticket *LoginTicketResponse, fresh bool, err error) {
+	for attempt := 0; ; attempt++ {
+		ticket, err = s.login(ctx, serviceName)
+		if err == nil {
+			return ticket, true, nil
+		}
+		if !errors.Is(err, ErrAlreadyAuthenticated) {
+			return nil, false, err
 		}
 
-		// Llamo al servicio de autenticación afip wssa
-		responseXML, err := login.LoginCms(&request)
-		if err != nil {
-			return "", "", "", fmt.Errorf("GetLoginTicket: %s", err)
+		if fallback := s.fallbackTicket(key, previous, rejected); fallback != nil {
+			return fallback, false, nil
 		}
 
-		// Logeo respuesta
-		if s.environment == TESTING {
-			fmt.Printf("RESPONSE XML:\n%s\n\n", responseXML)
+		if attempt >= s.alreadyAuthRetries {
+			return nil, false, err
 		}
 
-		// Desarmo respuesta XML
-		response := LoginTicketResponse{}
-		if err := xml.Unmarshal([]byte(responseXML.LoginCmsReturn), &response); err != nil {
-			return "", "", "", fmt.Errorf("GetLoginTicket: Error desarmando respuesta XML. %s", err)
+		select {
+		case <-time.After(s.alreadyAuthWait):
+		case <-ctx.Done():
+			return nil, false, fmt.Errorf("GetLoginTicket: %s", ctx.Err())
 		}
+	}
+}
 
-		// Almaceno ticket de respuesta (porque no se puede llamar nuevamente al servicio hasta dentro de 10 minutos,
-		// hay que seguir usando el ticket actual. El vencimiento de los ticket de afip suele ser de 12 horas)
-		s.tickets[serviceName] = &response
+// fallbackTicket devuelve el ticket anterior o el persistido si todavía no vencieron
+func (s *Service) fallbackTicket(key string, previous *LoginTicketResponse, rejected string) *LoginTicketResponse {
+	stored, _ := s.store.Load(key)
+	for _, ticket := range []*LoginTicketResponse{previous, stored} {
+		if ticket == nil || ticket.Credentials.Token == rejected {
+			continue
+		}
+
+		expTime, err := time.Parse(time.RFC3339, ticket.Header.ExpirationTime)
+		if err == nil && s.now().Before(expTime) {
+			return ticket
+		}
+	}
+	return nil
+}
+
+// RenewTicket indica que el servicio de negocio rechazó rejectedToken. Si sigue siendo el token
+// vigente, el próximo GetLoginTicket lo descarta y solicita uno nuevo a WSAA; si ya fue
+// reemplazado (por ejemplo por otra goroutine que recibió el mismo rechazo) no tiene efecto.
+func (s *Service) RenewTicket(serviceName, rejectedToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ticket := s.tickets[serviceName]; ticket != nil && ticket.Credentials.Token == rejectedToken {
+		s.renew[serviceName] = rejectedToken
+	}
+}
+
+// serviceLock devuelve el lock (canal de capacidad 1) que serializa los logins de un servicio
+func (s *Service) serviceLock(serviceName string) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.locks[serviceName]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		s.locks[serviceName] = lock
+	}
+	return lock
+}
+
+// login solicita un nuevo ticket de acceso a WSAA
+func (s *Service) login(ctx context.Context, serviceName string) (*LoginTicketResponse, error) {
+	now := s.now()
+	generationTime := now.Add(-s.requestTTL).Format(time.RFC3339)
+	expirationTime := now.Add(s.requestTTL).Format(time.RFC3339)
+
+	// Armo estructura request
+	loginTicketRequest := LoginTicketRequest{
+		Version: "1.0",
+		Header: &HeaderLoginTicket{
+			UniqueID:       nextUniqueID(now),
+			GenerationTime: generationTime,
+			ExpirationTime: expirationTime,
+		},
+		Service: serviceName,
+	}
+
+	// Armo XML
+	loginTicketRequestXML, err := xml.MarshalIndent(loginTicketRequest, " ", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("GetLoginTicket: Error armando login ticket request XML. %s", err)
+	}
+	content := []byte(string(loginTicketRequestXML))
+
+	// Creo CMS (Cryptographic Message Syntax)
+	certificate, signer, err := s.credentials.KeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("GetLoginTicket: %s", err)
+	}
+	opts := s.cmsOptions
+	if source, ok := s.credentials.(ChainCredentialSource); ok {
+		chain, err := source.Chain()
+		if err != nil {
+			return nil, fmt.Errorf("GetLoginTicket: %s", err)
+		}
+		// La cadena de la fuente va primero para que WithCMSOptions pueda reemplazarla
+		opts = append([]certs.CMSOption{certs.WithChain(chain...)}, s.cmsOptions...)
+	}
+	cms, err := certs.EncodeCMS(content, certificate, signer, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("GetLoginTicket: %s", err)
+	}
+
+	// Convierto CMS a base64
+	cmsBase64 := base64.StdEncoding.EncodeToString(cms)
+
+	// Armo conexión SOAP y solicitud
+	login := NewLoginCMS(s.newSoapClient())
+
+	request := LoginCms{In0: cmsBase64}
+
+	// Logeo solicitud
+	if s.environment == TESTING {
+		requestXML, _ := xml.MarshalIndent(request, " ", "  ")
+		fmt.Printf("REQUEST XML:\n%s\n\n", xml.Header+string(requestXML))
+	}
+
+	// Llamo al servicio de autenticación afip wssa
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	responseXML, err := login.LoginCmsContext(ctx, &request)
+	if err != nil {
+		return nil, parseLoginFault(err)
+	}
+
+	// Logeo respuesta
+	if s.environment == TESTING {
+		fmt.Printf("RESPONSE XML:\n%s\n\n", responseXML)
+	}
+
+	// Desarmo respuesta XML
+	response := LoginTicketResponse{}
+	if err := xml.Unmarshal([]byte(responseXML.LoginCmsReturn), &response); err != nil {
+		return nil, fmt.Errorf("GetLoginTicket: Error desarmando respuesta XML. %s", err)
 	}
 
-	return s.tickets[serviceName].Credentials.Token, s.tickets[serviceName].Credentials.Sign, s.tickets[serviceName].Header.ExpirationTime, nil
+	return &response, nil
 }
@@ -0,0 +1,177 @@
+package wsafip
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sisuani/gowsfe/pkg/certs"
+)
+
+// testKeyPair genera un certificado autofirmado y su clave en dir
+func testKeyPair(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	certFile, keyFile := filepath.Join(dir, "cert.crt"), filepath.Join(dir, "cert.key")
+	key, err := certs.GenerateKey(keyFile, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test", SerialNumber: "CUIT 20111111112"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// fakeWSAA responde a cada login con lo que devuelve respond (un ticket o un fault)
+type fakeWSAA struct {
+	logins  int32
+	respond func(login int32, w http.ResponseWriter)
+}
+
+func (f *fakeWSAA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.respond(atomic.AddInt32(&f.logins, 1), w)
+}
+
+func writeTicket(w http.ResponseWriter, token string, ttl time.Duration) {
+	now := time.Now()
+	ticket := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><loginTicketResponse version="1.0"><header><source>CN=wsaahomo</source><destination>SERIALNUMBER=CUIT 20111111112, CN=test</destination><uniqueId>1</uniqueId><generationTime>%s</generationTime><expirationTime>%s</expirationTime></header><credentials><token>%s</token><sign>SIGN</sign></credentials></loginTicketResponse>`,
+		now.Format(time.RFC3339), now.Add(ttl).Format(time.RFC3339), token)
+	fmt.Fprintf(w, `<?xml version="1.0"?><soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><loginCmsResponse xmlns="http://wsaa.view.sua.dvadac.desein.afip.gov"><loginCmsReturn>%s</loginCmsReturn></loginCmsResponse></soapenv:Body></soapenv:Envelope>`, html.EscapeString(ticket))
+}
+
+func writeFault(w http.ResponseWriter, code, msg string) {
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<?xml version="1.0"?><soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><soapenv:Fault><faultcode xmlns:ns1="http://xml.apache.org/axis/">ns1:%s</faultcode><faultstring>%s</faultstring></soapenv:Fault></soapenv:Body></soapenv:Envelope>`, code, msg)
+}
+
+func newTestService(t *testing.T, wsaa *fakeWSAA, opts ...Option) *Service {
+	t.Helper()
+
+	server := httptest.NewServer(wsaa)
+	t.Cleanup(server.Close)
+
+	certFile, keyFile := testKeyPair(t, t.TempDir())
+	return NewService(PRODUCTION, certFile, keyFile, append([]Option{WithURL(server.URL)}, opts...)...)
+}
+
+func TestRenewTicketConcurrentRejections(t *testing.T) {
+	wsaa := &fakeWSAA{respond: func(login int32, w http.ResponseWriter) {
+		writeTicket(w, fmt.Sprintf("T%d", login), 12*time.Hour)
+	}}
+	auth := ForService(newTestService(t, wsaa), ServiceWSFE)
+	ctx := context.Background()
+
+	token, _, err := auth.Credentials(ctx, "")
+	if err != nil || token != "T1" {
+		t.Fatalf("Credentials() = %q, %v", token, err)
+	}
+
+	// Varias goroutines reciben 600 con el mismo token: solo debe renovarse una vez
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	errs := make([]error, len(tokens))
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], _, errs[i] = auth.Credentials(ctx, "T1")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, token := range tokens {
+		if errs[i] != nil || token != "T2" {
+			t.Errorf("goroutine %d: Credentials(T1) = %q, %v, want T2", i, token, errs[i])
+		}
+	}
+	if logins := atomic.LoadInt32(&wsaa.logins); logins != 2 {
+		t.Errorf("logins = %d, want 2", logins)
+	}
+
+	// El pedido de renovación ya fue atendido, no se vuelve a WSAA
+	if token, _, _ := auth.Credentials(ctx, ""); token != "T2" {
+		t.Errorf("token = %q, want T2", token)
+	}
+	if logins := atomic.LoadInt32(&wsaa.logins); logins != 2 {
+		t.Errorf("logins = %d, want 2", logins)
+	}
+}
+
+func TestRenewTicketFailedRelogin(t *testing.T) {
+	wsaa := &fakeWSAA{respond: func(login int32, w http.ResponseWriter) {
+		if login == 1 {
+			writeTicket(w, "T1", 12*time.Hour)
+			return
+		}
+		writeFault(w, "coe.alreadyAuthenticated", "El CEE ya posee un TA valido para el acceso al WSN solicitado")
+	}}
+	auth := ForService(newTestService(t, wsaa), ServiceWSFE)
+	ctx := context.Background()
+
+	if _, _, err := auth.Credentials(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := auth.Credentials(ctx, "T1"); !errors.Is(err, ErrAlreadyAuthenticated) {
+		t.Fatalf("Credentials(T1) error = %v, want ErrAlreadyAuthenticated", err)
+	}
+
+	// El re-login fallido no deja el pedido de renovación pendiente
+	for i := 0; i < 3; i++ {
+		token, _, err := auth.Credentials(ctx, "")
+		if err != nil || token != "T1" {
+			t.Fatalf("Credentials() = %q, %v", token, err)
+		}
+	}
+	if logins := atomic.LoadInt32(&wsaa.logins); logins != 2 {
+		t.Errorf("logins = %d, want 2", logins)
+	}
+}
+
+func TestRenewMarginAlreadyAuthenticated(t *testing.T) {
+	wsaa := &fakeWSAA{respond: func(login int32, w http.ResponseWriter) {
+		if login == 1 {
+			writeTicket(w, "T1", 5*time.Minute)
+			return
+		}
+		writeFault(w, "coe.alreadyAuthenticated", "El CEE ya posee un TA valido para el acceso al WSN solicitado")
+	}}
+	service := newTestService(t, wsaa, WithRenewMargin(10*time.Minute))
+
+	for i := 0; i < 3; i++ {
+		token, _, _, err := service.GetLoginTicket(ServiceWSFE)
+		if err != nil || token != "T1" {
+			t.Fatalf("GetLoginTicket() = %q, %v", token, err)
+		}
+	}
+
+	// Un único intento de renovación anticipada, luego se usa el ticket hasta que venza
+	if logins := atomic.LoadInt32(&wsaa.logins); logins != 2 {
+		t.Errorf("logins = %d, want 2", logins)
+	}
+}
@@ -0,0 +1,169 @@
+package wsafip
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// TicketStore persiste los tickets de acceso de WSAA entre reinicios del proceso.
+// Load devuelve nil sin error si no hay un ticket guardado para la clave.
+type TicketStore interface {
+	Load(key string) (*LoginTicketResponse, error)
+	Save(key string, ticket *LoginTicketResponse) error
+}
+
+// WithTicketStore establece dónde se persisten los tickets (por defecto en memoria)
+func WithTicketStore(store TicketStore) Option {
+	return func(s *Service) {
+		s.store = store
+	}
+}
+
+// MemoryTicketStore guarda los tickets en memoria
+type MemoryTicketStore struct {
+	mu      sync.Mutex
+	tickets map[string]*LoginTicketResponse
+}
+
+// NewMemoryTicketStore crea un TicketStore en memoria
+func NewMemoryTicketStore() *MemoryTicketStore {
+	return &MemoryTicketStore{tickets: make(map[string]*LoginTicketResponse)}
+}
+
+func (m *MemoryTicketStore) Load(key string) (*LoginTicketResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tickets[key], nil
+}
+
+func (m *MemoryTicketStore) Save(key string, ticket *LoginTicketResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tickets[key] = ticket
+	return nil
+}
+
+// FileTicketStore guarda cada ticket como XML en un archivo del directorio indicado.
+// Los archivos se escriben de forma atómica y solo son legibles por el usuario del proceso.
+type FileTicketStore struct {
+	dir string
+}
+
+// NewFileTicketStore crea un TicketStore en el directorio indicado
+func NewFileTicketStore(dir string) *FileTicketStore {
+	return &FileTicketStore{dir: dir}
+}
+
+func (f *FileTicketStore) path(key string) string {
+	return filepath.Join(f.dir, key+".xml")
+}
+
+func (f *FileTicketStore) Load(key string) (*LoginTicketResponse, error) {
+	data, err := ioutil.ReadFile(f.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FileTicketStore: %s", err)
+	}
+
+	ticket := LoginTicketResponse{}
+	if err := xml.Unmarshal(data, &ticket); err != nil {
+		return nil, fmt.Errorf("FileTicketStore: ticket inválido %s. %s", f.path(key), err)
+	}
+	if ticket.Header == nil || ticket.Credentials == nil {
+		return nil, fmt.Errorf("FileTicketStore: ticket incompleto %s", f.path(key))
+	}
+
+	return &ticket, nil
+}
+
+func (f *FileTicketStore) Save(key string, ticket *LoginTicketResponse) error {
+	data, err := xml.MarshalIndent(ticket, "", "  ")
+	if err != nil {
+		return fmt.Errorf("FileTicketStore: %s", err)
+	}
+
+	if err := os.MkdirAll(f.dir, 0700); err != nil {
+		return fmt.Errorf("FileTicketStore: %s", err)
+	}
+
+	// Escribo en un archivo temporal del mismo directorio y lo renombro
+	tmp, err := ioutil.TempFile(f.dir, "."+key+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("FileTicketStore: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("FileTicketStore: %s", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("FileTicketStore: %s", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("FileTicketStore: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("FileTicketStore: %s", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path(key)); err != nil {
+		return fmt.Errorf("FileTicketStore: %s", err)
+	}
+	return nil
+}
+
+// ticketKey identifica el ticket de un servicio para el certificado y environment del Service
+func (s *Service) ticketKey(serviceName string) string {
+	s.mu.Lock()
+	certID := s.certID
+	s.mu.Unlock()
+
+	if certID == "" {
+		// Si no se puede obtener el certificado no se guarda el identificador, el login fallará igualmente
+		crt, _, err := s.credentials.KeyPair()
+		if err != nil {
+			return strings.Join([]string{certFingerprint(s.cert), serviceName, s.environment.String()}, "_")
+		}
+		certID = certificateID(crt)
+
+		s.mu.Lock()
+		s.certID = certID
+		s.mu.Unlock()
+	}
+	return strings.Join([]string{certID, serviceName, s.environment.String()}, "_")
+}
+
+// certificateID devuelve un identificador corto derivado del hash SHA-256 del certificado
+func certificateID(crt *x509.Certificate) string {
+	sum := sha256.Sum256(crt.Raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+// certFingerprint devuelve un identificador del certificado; si no puede leerse se usa la ruta
+func certFingerprint(certFile string) string {
+	data, err := ioutil.ReadFile(certFile)
+	if err == nil {
+		if block, _ := pem.Decode(data); block != nil {
+			if crt, err := x509.ParseCertificate(block.Bytes); err == nil {
+				return certificateID(crt)
+			}
+		}
+	}
+
+	sum := sha256.Sum256([]byte(certFile))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,75 @@
+package wsafip
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFileTicketStore(t *testing.T) {
+	ticket := &LoginTicketResponse{
+		Header:      &HeaderLoginTicket{Source: "CN=wsaahomo", UniqueID: 1, ExpirationTime: "2024-03-15T18:30:00.000-03:00"},
+		Credentials: &Credentials{Token: "TOKEN", Sign: "SIGN"},
+	}
+
+	tests := []struct {
+		name    string
+		content string // contenido previo del archivo, vacío para no crearlo
+		save    *LoginTicketResponse
+		want    *LoginTicketResponse
+		wantErr bool
+	}{
+		{"inexistente", "", nil, nil, false},
+		{"guardado", "", ticket, ticket, false},
+		{"reemplaza el anterior", "<loginTicketResponse><header></header><credentials><token>OLD</token></credentials></loginTicketResponse>", ticket, ticket, false},
+		{"XML inválido", "<loginTicketResponse>", nil, nil, true},
+		{"sin credenciales", "<loginTicketResponse><header><uniqueId>1</uniqueId></header></loginTicketResponse>", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "tickets")
+			store := NewFileTicketStore(dir)
+			if tt.content != "" {
+				if err := os.MkdirAll(dir, 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(store.path("key"), []byte(tt.content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.save != nil {
+				if err := store.Save("key", tt.save); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := store.Load("key")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("Load() = %+v, want nil", got)
+			case tt.want != nil && (got == nil || *got.Header != *tt.want.Header || *got.Credentials != *tt.want.Credentials):
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+
+			if tt.save == nil {
+				return
+			}
+			files, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 {
+				t.Errorf("Save() left %d files in the directory, want 1", len(files))
+			}
+			if runtime.GOOS != "windows" && files[0].Mode().Perm() != 0600 {
+				t.Errorf("Save() file mode = %v, want 0600", files[0].Mode().Perm())
+			}
+		})
+	}
+}
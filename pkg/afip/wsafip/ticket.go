@@ -0,0 +1,81 @@
+package wsafip
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LoginTicket es una estructura que representa un ticket de un servicio de afip
+type LoginTicket struct {
+	ServiceName    string      `json:"serviceName"`
+	Token          string      `json:"token"`
+	Sign           string      `json:"sign"`
+	GenerationTime time.Time   `json:"generationTime"`
+	ExpirationTime time.Time   `json:"expirationTime"`
+	Source         string      `json:"source"`
+	Destination    string      `json:"destination"`
+	Environment    Environment `json:"environment"`
+
+	// corrección del reloj local del Service que obtuvo el ticket (ver SyncClock)
+	clockOffset time.Duration
+}
+
+// Ticket devuelve el ticket vigente del servicio, solicitando uno nuevo a WSAA si es necesario
+func (s *Service) Ticket(ctx context.Context, serviceName string) (*LoginTicket, error) {
+	response, err := s.loginTicket(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLoginTicket(serviceName, s.environment, s.ClockOffset(), response)
+}
+
+func newLoginTicket(serviceName string, environment Environment, clockOffset time.Duration, response *LoginTicketResponse) (*LoginTicket, error) {
+	ticket := &LoginTicket{
+		ServiceName: serviceName,
+		Token:       response.Credentials.Token,
+		Sign:        response.Credentials.Sign,
+		Source:      response.Header.Source,
+		Destination: response.Header.Destination,
+		Environment: environment,
+		clockOffset: clockOffset,
+	}
+
+	var err error
+	if ticket.ExpirationTime, err = time.Parse(time.RFC3339, response.Header.ExpirationTime); err != nil {
+		return nil, fmt.Errorf("Ticket: Error parseando fecha de expiración del ticket. %s", err)
+	}
+	// generationTime es informativo, un valor ilegible se deja en cero
+	ticket.GenerationTime, _ = time.Parse(time.RFC3339, response.Header.GenerationTime)
+
+	return ticket, nil
+}
+
+// now devuelve la hora local corregida con el offset del reloj vigente al obtener el ticket
+func (t *LoginTicket) now() time.Time {
+	return time.Now().Add(t.clockOffset)
+}
+
+// ExpiresIn devuelve el tiempo restante hasta el vencimiento del ticket (negativo si ya venció)
+func (t *LoginTicket) ExpiresIn() time.Duration {
+	return t.ExpirationTime.Sub(t.now())
+}
+
+// Expired indica si el ticket ya venció
+func (t *LoginTicket) Expired() bool {
+	return !t.now().Before(t.ExpirationTime)
+}
+
+// ExpiresWithin indica si el ticket vence dentro de d
+func (t *LoginTicket) ExpiresWithin(d time.Duration) bool {
+	return t.ExpiresIn() <= d
+}
+
+// Lifetime devuelve la vigencia total del ticket otorgada por WSAA
+func (t *LoginTicket) Lifetime() time.Duration {
+	if t.GenerationTime.IsZero() {
+		return 0
+	}
+	return t.ExpirationTime.Sub(t.GenerationTime)
+}
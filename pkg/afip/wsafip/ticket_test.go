@@ -0,0 +1,49 @@
+package wsafip
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginTicketClockOffset(t *testing.T) {
+	// WSAA informa los vencimientos con su hora, que puede diferir de la hora local
+	expiration := time.Now().Add(10 * time.Minute)
+	response := &LoginTicketResponse{
+		Header: &HeaderLoginTicket{
+			GenerationTime: expiration.Add(-12 * time.Hour).Format(time.RFC3339),
+			ExpirationTime: expiration.Format(time.RFC3339),
+		},
+		Credentials: &Credentials{Token: "TOKEN", Sign: "SIGN"},
+	}
+
+	tests := []struct {
+		name        string
+		clockOffset time.Duration
+		wantExpired bool
+		wantWithin  bool // vence dentro de 15 minutos
+	}{
+		{"reloj sincronizado", 0, false, true},
+		{"reloj local atrasado", 15 * time.Minute, true, true},
+		{"reloj local adelantado", -15 * time.Minute, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket, err := newLoginTicket(ServiceWSFE, TESTING, tt.clockOffset, response)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := ticket.Expired(); got != tt.wantExpired {
+				t.Errorf("Expired() = %v, want %v", got, tt.wantExpired)
+			}
+			if got := ticket.ExpiresWithin(15 * time.Minute); got != tt.wantWithin {
+				t.Errorf("ExpiresWithin(15m) = %v, want %v", got, tt.wantWithin)
+			}
+			want := 10*time.Minute - tt.clockOffset
+			if got := ticket.ExpiresIn(); got > want || got < want-time.Minute {
+				t.Errorf("ExpiresIn() = %s, want about %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package wsfe
+
+import (
+	"context"
+	"fmt"
+)
+
+// GetCompTotXRequest devuelve la cantidad máxima de comprobantes que acepta AFIP en un FECAESolicitar
+func (s *Service) GetCompTotXRequest(cuit int64) (int32, error) {
+	return s.GetCompTotXRequestContext(context.Background(), cuit)
+}
+
+// GetCompTotXRequestContext es como GetCompTotXRequest pero permite cancelar la llamada o fijar su timeout con ctx
+func (s *Service) GetCompTotXRequestContext(ctx context.Context, cuit int64) (int32, error) {
+	feCompTotXRequest := FECompTotXRequest{}
+
+	ctx, cancel, timeout := s.withTimeout(ctx)
+	defer cancel()
+
+	var result *FERegXReqResponse
+	err := s.conAuth(ctx, cuit, func(auth *FEAuthRequest) error {
+		feCompTotXRequest.Auth = auth
+		feCompTotXRequestResponse, err := s.serviceSoap.FECompTotXRequestContext(ctx, &feCompTotXRequest)
+		if err != nil {
+			return wrapSoapError(err, timeout)
+		}
+
+		result = feCompTotXRequestResponse.FECompTotXRequestResult
+		return newAFIPError(mensajesErr(result.Errors))
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return result.RegXReq, nil
+}
+
+// CaeRequestBatch solicita CAE para varios comprobantes de la misma cabecera, agrupándolos
+// en tantas llamadas a FECAESolicitar como indique el límite de FECompTotXRequest.
+// Devuelve un resultado por cada comprobante, en el mismo orden que caeRequests. Si falla la
+// llamada de un lote posterior al primero se devuelven los resultados de los lotes anteriores
+// (que AFIP ya procesó) junto con el error.
+func (s *Service) CaeRequestBatch(cabRequest *CabRequest, caeRequests []*CaeRequest) ([]*CaeResult, error) {
+	return s.CaeRequestBatchContext(context.Background(), cabRequest, caeRequests)
+}
+
+// CaeRequestBatchContext es como CaeRequestBatch pero permite cancelar las llamadas o fijar su timeout con ctx
+func (s *Service) CaeRequestBatchContext(ctx context.Context, cabRequest *CabRequest, caeRequests []*CaeRequest) ([]*CaeResult, error) {
+	if len(caeRequests) == 0 {
+		return nil, nil
+	}
+
+	feDetRequests := make([]*FECAEDetRequest, 0, len(caeRequests))
+	for i, caeRequest := range caeRequests {
+		feCAEDetRequest, err := s.buildDetRequest(ctx, cabRequest, caeRequest)
+		if err != nil {
+			return nil, fmt.Errorf("comprobante %d: %w", i, err)
+		}
+		feDetRequests = append(feDetRequests, feCAEDetRequest)
+	}
+
+	porLote, err := s.GetCompTotXRequestContext(ctx, cabRequest.Cuit)
+	if err != nil {
+		return nil, err
+	}
+	if porLote <= 0 {
+		porLote = 1
+	}
+
+	results := make([]*CaeResult, 0, len(caeRequests))
+	for desde := 0; desde < len(feDetRequests); desde += int(porLote) {
+		hasta := desde + int(porLote)
+		if hasta > len(feDetRequests) {
+			hasta = len(feDetRequests)
+		}
+
+		lote, err := s.solicitarLote(ctx, cabRequest, feDetRequests[desde:hasta])
+		if err != nil {
+			return results, err
+		}
+		results = append(results, lote...)
+	}
+
+	return results, nil
+}
+
+func (s *Service) solicitarLote(ctx context.Context, cabRequest *CabRequest, feDetRequests []*FECAEDetRequest) ([]*CaeResult, error) {
+	feCAERequest := FECAERequest{
+		FeCabReq: &FECAECabRequest{
+			FECabRequest: &FECabRequest{
+				CantReg:  int32(len(feDetRequests)),
+				PtoVta:   cabRequest.PtoVta,
+				CbteTipo: cabRequest.CbteTipo,
+			},
+		},
+		FeDetReq: &ArrayOfFECAEDetRequest{
+			FECAEDetRequest: feDetRequests,
+		},
+	}
+
+	feCaeSolicitar := FECAESolicitar{
+		FeCAEReq: &feCAERequest,
+	}
+
+	ctx, cancel, timeout := s.withTimeout(ctx)
+	defer cancel()
+
+	// Los rechazos se devuelven en el resultado, solo los errores de autenticación
+	// se informan a conAuth para renovar las credenciales y reintentar
+	var feCAESolicitarResult *FECAEResponse
+	err := s.conAuth(ctx, cabRequest.Cuit, func(auth *FEAuthRequest) error {
+		feCaeSolicitar.Auth = auth
+		feCAESolicitarResponse, err := s.serviceSoap.FECAESolicitarContext(ctx, &feCaeSolicitar)
+		if err != nil {
+			return wrapSoapError(err, timeout)
+		}
+
+		feCAESolicitarResult = feCAESolicitarResponse.FECAESolicitarResult
+		if feCAESolicitarResult == nil {
+			return fmt.Errorf("AFIP devolvió una respuesta vacía a FECAESolicitar")
+		}
+		if err := newAFIPError(mensajesErr(feCAESolicitarResult.Errors)); IsAuthExpired(err) {
+			return err
+		}
+		return nil
+	})
+	if err != nil && !IsAuthExpired(err) {
+		return nil, err
+	}
+
+	errs := mensajesErr(feCAESolicitarResult.Errors)
+	events := mensajesEvt(feCAESolicitarResult.Events)
+
+	fchProceso := ""
+	if feCAESolicitarResult.FeCabResp != nil && feCAESolicitarResult.FeCabResp.FECabResponse != nil {
+		fchProceso = feCAESolicitarResult.FeCabResp.FchProceso
+	}
+
+	// AFIP responde los detalles en el orden enviado, se indexan por número por seguridad
+	respuestas := make(map[int64]*FECAEDetResponse)
+	if feCAESolicitarResult.FeDetResp != nil {
+		for _, feCAEDetResponse := range feCAESolicitarResult.FeDetResp.FECAEDetResponse {
+			if feCAEDetResponse.FEDetResponse != nil {
+				respuestas[feCAEDetResponse.CbteDesde] = feCAEDetResponse
+			}
+		}
+	}
+
+	results := make([]*CaeResult, 0, len(feDetRequests))
+	for _, feDetRequest := range feDetRequests {
+		result := &CaeResult{
+			Resultado:  ResultadoRechazado,
+			FchProceso: fchProceso,
+			CbteDesde:  feDetRequest.CbteDesde,
+			CbteHasta:  feDetRequest.CbteHasta,
+			Errors:     errs,
+			Events:     events,
+		}
+
+		if feCAEDetResponse, ok := respuestas[feDetRequest.CbteDesde]; ok {
+			result.Resultado = feCAEDetResponse.Resultado
+			result.CAE = feCAEDetResponse.CAE
+			result.CAEFchVto = feCAEDetResponse.CAEFchVto
+			result.Observaciones = mensajesObs(feCAEDetResponse.Observaciones)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
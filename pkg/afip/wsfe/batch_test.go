@@ -0,0 +1,87 @@
+package wsfe
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// writeCAELote responde FECAESolicitar aprobando los comprobantes desde..hasta, en orden inverso
+// para verificar que los resultados se asocian por número
+func writeCAELote(w http.ResponseWriter, desde, hasta int64) {
+	var detalles strings.Builder
+	for nro := hasta; nro >= desde; nro-- {
+		fmt.Fprintf(&detalles, `<FECAEDetResponse><Concepto>1</Concepto><DocTipo>99</DocTipo><DocNro>0</DocNro><CbteDesde>%[1]d</CbteDesde><CbteHasta>%[1]d</CbteHasta><Resultado>A</Resultado><CAE>7400000000000%[1]d</CAE><CAEFchVto>20240325</CAEFchVto></FECAEDetResponse>`, nro)
+	}
+	writeSoap(w, fmt.Sprintf(`<FECAESolicitarResponse xmlns="http://ar.gov.afip.dif.FEV1/"><FECAESolicitarResult><FeCabResp><Cuit>20111111112</Cuit><PtoVta>1</PtoVta><CbteTipo>11</CbteTipo><FchProceso>20240315</FchProceso><CantReg>%d</CantReg><Resultado>A</Resultado></FeCabResp><FeDetResp>%s</FeDetResp></FECAESolicitarResult></FECAESolicitarResponse>`, hasta-desde+1, detalles.String()))
+}
+
+func TestCaeRequestBatchLotes(t *testing.T) {
+	tests := []struct {
+		name        string
+		falla       int // llamada a FECAESolicitar que corta la conexión, 0 para ninguna
+		wantResults int
+		wantErr     bool
+	}{
+		{"todos los lotes", 0, 5, false},
+		{"falla el segundo lote", 2, 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wsfe := &fakeWSFE{}
+			wsfe.respond = func(operation string, call int, w http.ResponseWriter) {
+				switch operation {
+				case "FECompTotXRequest":
+					writeSoap(w, `<FECompTotXRequestResponse xmlns="http://ar.gov.afip.dif.FEV1/"><FECompTotXRequestResult><RegXReq>2</RegXReq></FECompTotXRequestResult></FECompTotXRequestResponse>`)
+				case "FECAESolicitar":
+					if call == tt.falla {
+						closeConnection(t, w)
+						return
+					}
+					desde := int64(2*call - 1)
+					hasta := desde + 1
+					if hasta > 5 {
+						hasta = 5
+					}
+					writeCAELote(w, desde, hasta)
+				default:
+					t.Errorf("unexpected operation %s", operation)
+				}
+			}
+			server := httptest.NewServer(wsfe)
+			defer server.Close()
+
+			caeRequests := make([]*CaeRequest, 5)
+			for i := range caeRequests {
+				nro := int64(i + 1)
+				caeRequests[i] = &CaeRequest{DocTipo: 99, CbteDesde: nro, CbteHasta: nro, ImpTotal: 100, ImpNeto: 100}
+			}
+
+			s := NewService(TESTING, "TOKEN", "SIGN", WithURL(server.URL))
+			results, err := s.CaeRequestBatch(&CabRequest{Cuit: 20111111112, PtoVta: 1, CbteTipo: FacturaC}, caeRequests)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CaeRequestBatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(results) != tt.wantResults {
+				t.Fatalf("CaeRequestBatch() returned %d results, want %d", len(results), tt.wantResults)
+			}
+			for i, result := range results {
+				nro := int64(i + 1)
+				if result.CbteDesde != nro || result.CAE != fmt.Sprintf("7400000000000%d", nro) || result.Resultado != ResultadoAprobado {
+					t.Errorf("result %d = %d %s %s, want comprobante %d approved", i, result.CbteDesde, result.CAE, result.Resultado, nro)
+				}
+			}
+
+			wantLlamadas := 3
+			if tt.falla != 0 {
+				wantLlamadas = tt.falla
+			}
+			if got := wsfe.count("FECAESolicitar"); got != wantLlamadas {
+				t.Errorf("FECAESolicitar called %d times, want %d", got, wantLlamadas)
+			}
+		})
+	}
+}
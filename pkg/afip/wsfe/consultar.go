@@ -0,0 +1,156 @@
+package wsfe
+
+import (
+	"context"
+	"fmt"
+)
+
+// Tipos de emisión informados por FECompConsultar
+const (
+	EmisionCAE  = "CAE"
+	EmisionCAEA = "CAEA"
+)
+
+// ComprobanteIva es una alícuota de IVA de un comprobante consultado
+type ComprobanteIva struct {
+	ID      int32   `json:"id"`
+	BaseImp float64 `json:"baseImp"`
+	Importe float64 `json:"importe"`
+}
+
+// ComprobanteTributo es un tributo de un comprobante consultado
+type ComprobanteTributo struct {
+	ID      int16   `json:"id"`
+	Desc    string  `json:"desc"`
+	BaseImp float64 `json:"baseImp"`
+	Alic    float64 `json:"alic"`
+	Importe float64 `json:"importe"`
+}
+
+// Comprobante es la vista de un comprobante autorizado devuelta por ConsultarComprobante
+type Comprobante struct {
+	PtoVta                 int32                `json:"ptoVta"`
+	CbteTipo               int32                `json:"cbteTipo"`
+	Concepto               int32                `json:"concepto"`
+	DocTipo                int32                `json:"docTipo"`
+	DocNro                 int64                `json:"docNro"`
+	CbteDesde              int64                `json:"cbteDesde"`
+	CbteHasta              int64                `json:"cbteHasta"`
+	CbteFch                string               `json:"cbteFch"`
+	ImpTotal               float64              `json:"impTotal"`
+	ImpTotConc             float64              `json:"impTotConc"`
+	ImpNeto                float64              `json:"impNeto"`
+	ImpOpEx                float64              `json:"impOpEx"`
+	ImpTrib                float64              `json:"impTrib"`
+	ImpIVA                 float64              `json:"impIVA"`
+	FchServDesde           string               `json:"fchServDesde"`
+	FchServHasta           string               `json:"fchServHasta"`
+	FchVtoPago             string               `json:"fchVtoPago"`
+	MonId                  string               `json:"monId"`
+	MonCotiz               float64              `json:"monCotiz"`
+	CanMisMonExt           string               `json:"canMisMonExt"`
+	CondicionIVAReceptorId int32                `json:"condicionIVAReceptorId"`
+	Ivas                   []ComprobanteIva     `json:"ivas,omitempty"`
+	Tributos               []ComprobanteTributo `json:"tributos,omitempty"`
+	Resultado              string               `json:"resultado"`
+	CodAutorizacion        string               `json:"codAutorizacion"`
+	EmisionTipo            string               `json:"emisionTipo"`
+	FchVto                 string               `json:"fchVto"`
+	FchProceso             string               `json:"fchProceso"`
+	Observaciones          []Mensaje            `json:"observaciones,omitempty"`
+}
+
+// ConsultarComprobante devuelve los datos de un comprobante autorizado (FECompConsultar).
+// Si el comprobante no existe AFIP devuelve el error 602 (ver IsNotFound).
+func (s *Service) ConsultarComprobante(cuit int64, ptoVta, cbteTipo int32, nro int64) (*Comprobante, error) {
+	return s.ConsultarComprobanteContext(context.Background(), cuit, ptoVta, cbteTipo, nro)
+}
+
+// ConsultarComprobanteContext es como ConsultarComprobante pero permite cancelar la llamada o fijar su timeout con ctx
+func (s *Service) ConsultarComprobanteContext(ctx context.Context, cuit int64, ptoVta, cbteTipo int32, nro int64) (*Comprobante, error) {
+	feCompConsultar := FECompConsultar{
+		FeCompConsReq: &FECompConsultaReq{
+			CbteTipo: cbteTipo,
+			CbteNro:  nro,
+			PtoVta:   ptoVta,
+		},
+	}
+
+	ctx, cancel, timeout := s.withTimeout(ctx)
+	defer cancel()
+
+	var result *FECompConsultaResponse
+	err := s.conAuth(ctx, cuit, func(auth *FEAuthRequest) error {
+		feCompConsultar.Auth = auth
+		feCompConsultarResponse, err := s.serviceSoap.FECompConsultarContext(ctx, &feCompConsultar)
+		if err != nil {
+			return wrapSoapError(err, timeout)
+		}
+
+		result = feCompConsultarResponse.FECompConsultarResult
+		if result == nil {
+			return fmt.Errorf("AFIP devolvió una respuesta vacía a FECompConsultar")
+		}
+		return newAFIPError(mensajesErr(result.Errors))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.ResultGet == nil {
+		return nil, newAFIPError([]Mensaje{{Code: ErrCodeSinResultados, Msg: "comprobante inexistente"}})
+	}
+
+	resultGet := result.ResultGet
+	comprobante := Comprobante{
+		PtoVta:          resultGet.PtoVta,
+		CbteTipo:        resultGet.CbteTipo,
+		Resultado:       resultGet.Resultado,
+		CodAutorizacion: resultGet.CodAutorizacion,
+		EmisionTipo:     resultGet.EmisionTipo,
+		FchVto:          resultGet.FchVto,
+		FchProceso:      resultGet.FchProceso,
+		Observaciones:   mensajesObs(resultGet.Observaciones),
+	}
+
+	if resultGet.FECAEDetRequest != nil && resultGet.FEDetRequest != nil {
+		det := resultGet.FEDetRequest
+		comprobante.Concepto = det.Concepto
+		comprobante.DocTipo = det.DocTipo
+		comprobante.DocNro = det.DocNro
+		comprobante.CbteDesde = det.CbteDesde
+		comprobante.CbteHasta = det.CbteHasta
+		comprobante.CbteFch = det.CbteFch
+		comprobante.ImpTotal = det.ImpTotal
+		comprobante.ImpTotConc = det.ImpTotConc
+		comprobante.ImpNeto = det.ImpNeto
+		comprobante.ImpOpEx = det.ImpOpEx
+		comprobante.ImpTrib = det.ImpTrib
+		comprobante.ImpIVA = det.ImpIVA
+		comprobante.FchServDesde = det.FchServDesde
+		comprobante.FchServHasta = det.FchServHasta
+		comprobante.FchVtoPago = det.FchVtoPago
+		comprobante.MonId = det.MonId
+		comprobante.MonCotiz = det.MonCotiz
+		comprobante.CanMisMonExt = det.CanMisMonExt
+		comprobante.CondicionIVAReceptorId = det.CondicionIVAReceptorId
+
+		if det.Iva != nil {
+			for _, iva := range det.Iva.AlicIva {
+				comprobante.Ivas = append(comprobante.Ivas, ComprobanteIva{ID: iva.Id, BaseImp: iva.BaseImp, Importe: iva.Importe})
+			}
+		}
+		if det.Tributos != nil {
+			for _, tributo := range det.Tributos.Tributo {
+				comprobante.Tributos = append(comprobante.Tributos, ComprobanteTributo{
+					ID:      tributo.Id,
+					Desc:    tributo.Desc,
+					BaseImp: tributo.BaseImp,
+					Alic:    tributo.Alic,
+					Importe: tributo.Importe,
+				})
+			}
+		}
+	}
+
+	return &comprobante, nil
+}
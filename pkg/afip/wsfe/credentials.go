@@ -0,0 +1,31 @@
+package wsfe
+
+import (
+	"context"
+
+	"github.com/sisuani/gowsfe/pkg/afip/wsafip"
+)
+
+// ServiceName es el nombre del servicio WSFE en WSAA
+const ServiceName = wsafip.ServiceWSFE
+
+// CredentialsProvider provee el token y sign vigentes para llamar a WSFE.
+// Si rejectedToken no es vacío AFIP rechazó ese token y, si sigue siendo el vigente, debe
+// solicitarse uno nuevo. Si ya fue reemplazado se devuelve el nuevo sin volver a WSAA.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context, rejectedToken string) (token, sign string, err error)
+}
+
+// WithCredentialsProvider obtiene token y sign del provider en cada llamada en lugar de
+// usar los valores fijos de NewService
+func WithCredentialsProvider(provider CredentialsProvider) Option {
+	return func(s *Service) {
+		s.credentials = provider
+	}
+}
+
+// NewAFIPCredentials crea un CredentialsProvider que obtiene los tickets de WSFE desde WSAA.
+// El ticket se renueva al vencer (o antes, ver wsafip.WithRenewMargin) y cuando AFIP lo rechaza.
+func NewAFIPCredentials(afip wsafip.AuthProvider) CredentialsProvider {
+	return wsafip.ForService(afip, ServiceName)
+}
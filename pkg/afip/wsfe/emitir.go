@@ -0,0 +1,88 @@
+package wsfe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MaxReintentosEmision es la cantidad de reintentos por defecto de Emitir ante un error de numeración (10016)
+const MaxReintentosEmision = 3
+
+// claveNumeracion identifica una secuencia de numeración de AFIP
+type claveNumeracion struct {
+	cuit     int64
+	ptoVta   int32
+	cbteTipo int32
+}
+
+// Los locks de numeración son del proceso: dos Service de la misma cuit (por ejemplo uno recreado
+// por tenants.Registry) no deben emitir en paralelo sobre la misma secuencia
+var (
+	locksNumeracionMu sync.Mutex
+	locksNumeracion   = make(map[claveNumeracion]chan struct{})
+)
+
+// lockNumeracion devuelve el lock (canal de capacidad 1) que serializa las emisiones de una
+// secuencia de numeración. Es un canal para poder abandonar la espera si se cancela el contexto.
+func lockNumeracion(cabRequest *CabRequest) chan struct{} {
+	locksNumeracionMu.Lock()
+	defer locksNumeracionMu.Unlock()
+
+	clave := claveNumeracion{cuit: cabRequest.Cuit, ptoVta: cabRequest.PtoVta, cbteTipo: cabRequest.CbteTipo}
+	lock, ok := locksNumeracion[clave]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		locksNumeracion[clave] = lock
+	}
+	return lock
+}
+
+// Emitir solicita el CAE asignando automáticamente el próximo número de comprobante.
+// Los valores de CbteDesde y CbteHasta del request se ignoran. Si AFIP informa que el número
+// está fuera de secuencia (10016) se vuelve a consultar el último autorizado y se reintenta.
+// Las emisiones de un mismo cuit, punto de venta y tipo de comprobante se serializan dentro del proceso,
+// aunque se realicen desde distintos Service.
+func (s *Service) Emitir(cabRequest *CabRequest, caeRequest *CaeRequest) (*CaeResult, error) {
+	return s.EmitirContext(context.Background(), cabRequest, caeRequest)
+}
+
+// EmitirContext es como Emitir pero permite cancelar las llamadas o fijar su timeout con ctx
+func (s *Service) EmitirContext(ctx context.Context, cabRequest *CabRequest, caeRequest *CaeRequest) (*CaeResult, error) {
+	lock := lockNumeracion(cabRequest)
+	select {
+	case lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-lock }()
+
+	reintentos := s.maxReintentos
+	if reintentos < 0 {
+		reintentos = 0
+	}
+
+	request := *caeRequest
+
+	var result *CaeResult
+	for intento := 0; intento <= reintentos; intento++ {
+		ultimo, err := s.GetUltimoCompContext(ctx, cabRequest)
+		if err != nil {
+			return nil, err
+		}
+
+		request.CbteDesde = int64(ultimo) + 1
+		request.CbteHasta = request.CbteDesde
+
+		result, err = s.SolicitarCAEContext(ctx, cabRequest, &request)
+		if err != nil {
+			return nil, err
+		}
+
+		if !result.HasCode(ErrCodeFueraDeSecuencia) {
+			return result, nil
+		}
+	}
+
+	return result, fmt.Errorf("no se pudo asignar número de comprobante luego de %d intentos: %w", reintentos+1, result.rechazo())
+}
@@ -0,0 +1,90 @@
+package wsfe
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// writeFueraDeSecuencia responde FECAESolicitar con el rechazo que AFIP informa como observación
+func writeFueraDeSecuencia(w http.ResponseWriter, cbteNro int64) {
+	writeSoap(w, fmt.Sprintf(`<FECAESolicitarResponse xmlns="http://ar.gov.afip.dif.FEV1/"><FECAESolicitarResult><FeCabResp><Cuit>20111111112</Cuit><PtoVta>1</PtoVta><CbteTipo>11</CbteTipo><FchProceso>20240315</FchProceso><CantReg>1</CantReg><Resultado>R</Resultado></FeCabResp><FeDetResp><FECAEDetResponse><Concepto>1</Concepto><DocTipo>99</DocTipo><DocNro>0</DocNro><CbteDesde>%[1]d</CbteDesde><CbteHasta>%[1]d</CbteHasta><Resultado>R</Resultado><Observaciones><Obs><Code>10016</Code><Msg>El numero o fecha del comprobante no se corresponde con el proximo a autorizar</Msg></Obs></Observaciones></FECAEDetResponse></FeDetResp></FECAESolicitarResult></FECAESolicitarResponse>`, cbteNro))
+}
+
+func TestEmitirFueraDeSecuencia(t *testing.T) {
+	tests := []struct {
+		name       string
+		reintentos int
+		rechazos   int // cantidad de FECAESolicitar que AFIP rechaza con 10016
+		wantCbte   int64
+		wantErr    bool
+		wantEnvios int
+	}{
+		{"reintenta con el próximo número", 3, 1, 6, false, 2},
+		{"agota los reintentos", 2, 10, 0, true, 3},
+		{"reintentos negativos", -1, 10, 0, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wsfe := &fakeWSFE{}
+			wsfe.respond = func(operation string, call int, w http.ResponseWriter) {
+				switch operation {
+				case "FECompUltimoAutorizado":
+					// Otro emisor autoriza un comprobante entre cada consulta
+					writeUltimoComp(w, int32(3+call))
+				case "FECAESolicitar":
+					cbteNro := int64(4 + call)
+					if call <= tt.rechazos {
+						writeFueraDeSecuencia(w, cbteNro)
+						return
+					}
+					writeCAE(w, cbteNro, "74000000000003")
+				default:
+					t.Errorf("unexpected operation %s", operation)
+				}
+			}
+			server := httptest.NewServer(wsfe)
+			defer server.Close()
+
+			s := NewService(TESTING, "TOKEN", "SIGN", WithURL(server.URL), WithMaxReintentos(tt.reintentos))
+			result, err := s.Emitir(&CabRequest{Cuit: 20111111112, PtoVta: 1, CbteTipo: FacturaC},
+				&CaeRequest{DocTipo: 99, ImpTotal: 100, ImpNeto: 100})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Emitir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !IsOutOfSequence(err) {
+				t.Errorf("Emitir() error = %v, want an out of sequence error", err)
+			}
+			if !tt.wantErr && (result.CbteDesde != tt.wantCbte || result.CAE != "74000000000003") {
+				t.Errorf("Emitir() = %d %s, want %d 74000000000003", result.CbteDesde, result.CAE, tt.wantCbte)
+			}
+			if got := wsfe.count("FECAESolicitar"); got != tt.wantEnvios {
+				t.Errorf("FECAESolicitar called %d times, want %d", got, tt.wantEnvios)
+			}
+			if got := wsfe.count("FECompUltimoAutorizado"); got != tt.wantEnvios {
+				t.Errorf("FECompUltimoAutorizado called %d times, want %d", got, tt.wantEnvios)
+			}
+		})
+	}
+}
+
+func TestEmitirCancelaEsperaDelLock(t *testing.T) {
+	cabRequest := &CabRequest{Cuit: 20111111112, PtoVta: 99, CbteTipo: FacturaC}
+
+	// Otra emisión de la misma secuencia que no termina
+	lock := lockNumeracion(cabRequest)
+	lock <- struct{}{}
+	defer func() { <-lock }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	s := NewService(TESTING, "TOKEN", "SIGN", WithURL("http://127.0.0.1:1"))
+	if _, err := s.EmitirContext(ctx, cabRequest, &CaeRequest{}); err != context.DeadlineExceeded {
+		t.Errorf("EmitirContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
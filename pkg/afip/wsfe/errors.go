@@ -0,0 +1,155 @@
+package wsfe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+)
+
+// Códigos de error de AFIP más relevantes
+const (
+	ErrCodeErrorInterno       = 500
+	ErrCodeErrorBaseDatos     = 501
+	ErrCodeTransaccionActiva  = 502
+	ErrCodeValidacionToken    = 600
+	ErrCodeCuitNoRepresentada = 601
+	ErrCodeSinResultados      = 602
+	ErrCodeFueraDeSecuencia   = 10016
+)
+
+// AFIPError es un error informado por AFIP en la respuesta del servicio.
+// Code y Msg corresponden al primer error, Errors contiene la lista completa.
+type AFIPError struct {
+	Code   int32
+	Msg    string
+	Errors []Mensaje
+}
+
+func (e *AFIPError) Error() string {
+	return fmt.Sprintf("error AFIP (código %d): %s", e.Code, e.Msg)
+}
+
+// HasCode indica si alguno de los errores informados tiene el código indicado
+func (e *AFIPError) HasCode(codes ...int32) bool {
+	for _, m := range e.Errors {
+		for _, code := range codes {
+			if m.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TimeoutError indica que AFIP no respondió dentro del tiempo de espera
+type TimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout: el servicio AFIP no respondió en %s", e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// TransportError indica que no se pudo completar la comunicación con AFIP
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("error de comunicación con AFIP: %s", e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+func newAFIPError(mensajes []Mensaje) error {
+	if len(mensajes) == 0 {
+		return nil
+	}
+	return &AFIPError{Code: mensajes[0].Code, Msg: mensajes[0].Msg, Errors: mensajes}
+}
+
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return false
+}
+
+// wrapSoapError clasifica los errores de la llamada SOAP en TimeoutError o TransportError.
+// La cancelación del contexto por parte del llamador se devuelve sin clasificar.
+func wrapSoapError(err error, timeout time.Duration) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	if isTimeoutError(err) {
+		return &TimeoutError{Timeout: timeout, Err: err}
+	}
+	return &TransportError{Err: err}
+}
+
+func hasAFIPCode(err error, codes ...int32) bool {
+	var afipErr *AFIPError
+	return errors.As(err, &afipErr) && afipErr.HasCode(codes...)
+}
+
+// IsAuthExpired indica si AFIP rechazó el token/sign (ticket vencido o inválido)
+func IsAuthExpired(err error) bool {
+	return hasAFIPCode(err, ErrCodeValidacionToken)
+}
+
+// IsCuitNoRepresentada indica si la cuit del request no está representada por el certificado
+// del token (falta la delegación del servicio en AFIP). Renovar el ticket no lo soluciona.
+func IsCuitNoRepresentada(err error) bool {
+	return hasAFIPCode(err, ErrCodeCuitNoRepresentada)
+}
+
+// IsOutOfSequence indica si el número de comprobante no es el próximo a autorizar
+func IsOutOfSequence(err error) bool {
+	return hasAFIPCode(err, ErrCodeFueraDeSecuencia)
+}
+
+// IsNotFound indica si AFIP no encontró datos para la consulta (comprobante inexistente)
+func IsNotFound(err error) bool {
+	return hasAFIPCode(err, ErrCodeSinResultados)
+}
+
+// IsServiceUnavailable indica si el servicio de AFIP no está disponible: no se pudo establecer la
+// conexión, respondió con un error HTTP 5xx o informó un error interno. Un corte de la conexión
+// luego de enviar el request no se considera, ya que AFIP pudo haberlo procesado.
+func IsServiceUnavailable(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var httpErr *soap.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode >= 500 {
+		return true
+	}
+	return hasAFIPCode(err, ErrCodeErrorInterno, ErrCodeErrorBaseDatos, ErrCodeTransaccionActiva)
+}
+
+// IsTimeout indica si AFIP no respondió dentro del tiempo de espera
+func IsTimeout(err error) bool {
+	var timeoutErr *TimeoutError
+	return errors.As(err, &timeoutErr)
+}
@@ -0,0 +1,71 @@
+package wsfe
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+
+	"github.com/hooklift/gowsdl/soap"
+)
+
+func TestIsServiceUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", &TransportError{Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}, true},
+		{"dns", &TransportError{Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "wswhomo.afip.gov.ar"}}}, true},
+		{"http 503", &TransportError{Err: &soap.HTTPError{StatusCode: 503}}, true},
+		{"http 404", &TransportError{Err: &soap.HTTPError{StatusCode: 404}}, false},
+		{"connection reset", &TransportError{Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}, false},
+		{"unexpected eof", &TransportError{Err: io.ErrUnexpectedEOF}, false},
+		{"error interno AFIP", newAFIPError([]Mensaje{{Code: ErrCodeErrorInterno, Msg: "error interno"}}), true},
+		{"token vencido", newAFIPError([]Mensaje{{Code: ErrCodeValidacionToken, Msg: "token vencido"}}), false},
+		{"otro error", errors.New("otro error"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsServiceUnavailable(tt.err); got != tt.want {
+				t.Errorf("IsServiceUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsServiceUnavailableLlamadas(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		closed  bool
+		want    bool
+	}{
+		{"servidor caído", nil, true, true},
+		{"http 503", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) }, false, true},
+		{"respuesta perdida", func(w http.ResponseWriter, r *http.Request) { closeConnection(t, w) }, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			if tt.closed {
+				server.Close()
+			}
+			defer server.Close()
+
+			s := NewService(TESTING, "TOKEN", "SIGN", WithURL(server.URL))
+			_, err := s.GetUltimoComp(&CabRequest{Cuit: 20111111112, PtoVta: 1, CbteTipo: FacturaC})
+			if err == nil {
+				t.Fatal("GetUltimoComp() succeeded, want an error")
+			}
+			if got := IsServiceUnavailable(err); got != tt.want {
+				t.Errorf("IsServiceUnavailable(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package wsfe
+
+import (
+	"fmt"
+	"time"
+)
+
+// FormatoFecha es el formato de fecha (yyyymmdd) que utiliza AFIP
+const FormatoFecha = "20060102"
+
+// Días permitidos entre la fecha del comprobante y la fecha actual según el concepto
+const (
+	DiasCbteFchProductos = 5
+	DiasCbteFchServicios = 10
+)
+
+func parseFecha(campo, fecha string) (time.Time, error) {
+	t, err := time.ParseInLocation(FormatoFecha, fecha, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s inválida (formato yyyymmdd): %s", campo, fecha)
+	}
+	return t, nil
+}
+
+func esNotaCredito(cbteTipo int32) bool {
+	return cbteTipo == NotaCreditoA || cbteTipo == NotaCreditoB || cbteTipo == NotaCreditoC
+}
+
+// validarFechas aplica las reglas de fechas de AFIP según el concepto del comprobante
+// antes de enviar la solicitud a FECAESolicitar.
+func validarFechas(cbteTipo, concepto int32, caeRequest *CaeRequest, now time.Time) error {
+	var dias int
+	switch concepto {
+	case ConceptoProductos:
+		dias = DiasCbteFchProductos
+	case ConceptoServicios, ConceptoProductosServicios:
+		dias = DiasCbteFchServicios
+	default:
+		return fmt.Errorf("concepto inválido: %d", concepto)
+	}
+
+	// CbteFch es opcional, si no se informa AFIP asigna la fecha de proceso
+	hoy := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	cbteFch := hoy
+	if caeRequest.CbteFch != "" {
+		var err error
+		cbteFch, err = parseFecha("CbteFch", caeRequest.CbteFch)
+		if err != nil {
+			return err
+		}
+
+		if cbteFch.Before(hoy.AddDate(0, 0, -dias)) || cbteFch.After(hoy.AddDate(0, 0, dias)) {
+			return fmt.Errorf("CbteFch %s fuera de rango: para concepto %d debe estar dentro de los %d días anteriores o posteriores a la fecha actual",
+				caeRequest.CbteFch, concepto, dias)
+		}
+	}
+
+	if concepto == ConceptoProductos {
+		if caeRequest.FchServDesde != "" || caeRequest.FchServHasta != "" || caeRequest.FchVtoPago != "" {
+			return fmt.Errorf("FchServDesde, FchServHasta y FchVtoPago no deben informarse para concepto %d", concepto)
+		}
+		return nil
+	}
+
+	if caeRequest.FchServDesde == "" || caeRequest.FchServHasta == "" {
+		return fmt.Errorf("FchServDesde y FchServHasta son obligatorias para concepto %d", concepto)
+	}
+
+	desde, err := parseFecha("FchServDesde", caeRequest.FchServDesde)
+	if err != nil {
+		return err
+	}
+	hasta, err := parseFecha("FchServHasta", caeRequest.FchServHasta)
+	if err != nil {
+		return err
+	}
+	if hasta.Before(desde) {
+		return fmt.Errorf("FchServHasta %s no puede ser anterior a FchServDesde %s", caeRequest.FchServHasta, caeRequest.FchServDesde)
+	}
+
+	// En notas de crédito la fecha de vencimiento de pago es opcional
+	if caeRequest.FchVtoPago == "" {
+		if esNotaCredito(cbteTipo) {
+			return nil
+		}
+		return fmt.Errorf("FchVtoPago es obligatoria para concepto %d", concepto)
+	}
+
+	vtoPago, err := parseFecha("FchVtoPago", caeRequest.FchVtoPago)
+	if err != nil {
+		return err
+	}
+	if vtoPago.Before(cbteFch) {
+		return fmt.Errorf("FchVtoPago %s no puede ser anterior a la fecha del comprobante %s", caeRequest.FchVtoPago, cbteFch.Format(FormatoFecha))
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package wsfe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidarFechas(t *testing.T) {
+	now := time.Date(2024, 3, 15, 18, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		cbteTipo int32
+		concepto int32
+		request  CaeRequest
+		wantErr  bool
+	}{
+		{"productos sin fecha", FacturaB, ConceptoProductos, CaeRequest{}, false},
+		{"productos dentro del rango", FacturaB, ConceptoProductos, CaeRequest{CbteFch: "20240310"}, false},
+		{"productos fuera del rango", FacturaB, ConceptoProductos, CaeRequest{CbteFch: "20240309"}, true},
+		{"productos fecha futura", FacturaB, ConceptoProductos, CaeRequest{CbteFch: "20240321"}, true},
+		{"productos con período de servicio", FacturaB, ConceptoProductos, CaeRequest{FchServDesde: "20240301"}, true},
+		{"fecha inválida", FacturaB, ConceptoProductos, CaeRequest{CbteFch: "15/03/2024"}, true},
+		{"concepto inválido", FacturaB, 4, CaeRequest{}, true},
+		{"servicios completo", FacturaA, ConceptoServicios, CaeRequest{CbteFch: "20240305", FchServDesde: "20240201", FchServHasta: "20240229", FchVtoPago: "20240325"}, false},
+		{"servicios fuera del rango", FacturaA, ConceptoServicios, CaeRequest{CbteFch: "20240304", FchServDesde: "20240201", FchServHasta: "20240229", FchVtoPago: "20240325"}, true},
+		{"servicios sin período", FacturaA, ConceptoServicios, CaeRequest{FchVtoPago: "20240325"}, true},
+		{"período invertido", FacturaA, ConceptoProductosServicios, CaeRequest{FchServDesde: "20240229", FchServHasta: "20240201", FchVtoPago: "20240325"}, true},
+		{"servicios sin vencimiento", FacturaA, ConceptoServicios, CaeRequest{FchServDesde: "20240201", FchServHasta: "20240229"}, true},
+		{"nota de crédito sin vencimiento", NotaCreditoA, ConceptoServicios, CaeRequest{FchServDesde: "20240201", FchServHasta: "20240229"}, false},
+		{"vencimiento anterior al comprobante", FacturaA, ConceptoServicios, CaeRequest{FchServDesde: "20240201", FchServHasta: "20240229", FchVtoPago: "20240314"}, true},
+		{"vencimiento anterior a CbteFch", FacturaA, ConceptoServicios, CaeRequest{CbteFch: "20240320", FchServDesde: "20240201", FchServHasta: "20240229", FchVtoPago: "20240318"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validarFechas(tt.cbteTipo, tt.concepto, &tt.request, now); (err != nil) != tt.wantErr {
+				t.Errorf("validarFechas() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
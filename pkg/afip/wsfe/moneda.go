@@ -0,0 +1,93 @@
+package wsfe
+
+import (
+	"context"
+	"fmt"
+)
+
+// Monedas más utilizadas (ver FEParamGetTiposMonedas)
+const (
+	MonedaPesos   = "PES"
+	MonedaDolares = "DOL"
+	MonedaEuros   = "060"
+)
+
+// SetCotizacionAutomatica habilita la consulta de la cotización oficial (FEParamGetCotizacion)
+// cuando se factura en moneda extranjera sin informar MonCotiz.
+func (s *Service) SetCotizacionAutomatica(enabled bool) {
+	s.cotizacionAutomatica = enabled
+}
+
+// GetCotizacion devuelve la cotización oficial de la moneda para la fecha indicada (yyyymmdd).
+// Si la fecha es vacía AFIP devuelve la última cotización disponible.
+func (s *Service) GetCotizacion(cuit int64, monId, fecha string) (float64, error) {
+	return s.GetCotizacionContext(context.Background(), cuit, monId, fecha)
+}
+
+// GetCotizacionContext es como GetCotizacion pero permite cancelar la llamada o fijar su timeout con ctx
+func (s *Service) GetCotizacionContext(ctx context.Context, cuit int64, monId, fecha string) (float64, error) {
+	feParamGetCotizacion := FEParamGetCotizacion{
+		MonId:    monId,
+		FchCotiz: fecha,
+	}
+
+	ctx, cancel, timeout := s.withTimeout(ctx)
+	defer cancel()
+
+	var result *FECotizacionResponse
+	err := s.conAuth(ctx, cuit, func(auth *FEAuthRequest) error {
+		feParamGetCotizacion.Auth = auth
+		feParamGetCotizacionResponse, err := s.serviceSoap.FEParamGetCotizacionContext(ctx, &feParamGetCotizacion)
+		if err != nil {
+			return wrapSoapError(err, timeout)
+		}
+
+		result = feParamGetCotizacionResponse.FEParamGetCotizacionResult
+		return newAFIPError(mensajesErr(result.Errors))
+	})
+	if err != nil {
+		return 0, err
+	}
+	if result.ResultGet == nil || result.ResultGet.MonCotiz <= 0 {
+		return 0, fmt.Errorf("AFIP no devolvió cotización para la moneda %s", monId)
+	}
+
+	return result.ResultGet.MonCotiz, nil
+}
+
+// resolverMoneda completa MonId, MonCotiz y CanMisMonExt del detalle a partir del request.
+func (s *Service) resolverMoneda(ctx context.Context, cuit int64, caeRequest *CaeRequest, feDetRequest *FEDetRequest) error {
+	monId := caeRequest.MonId
+	if monId == "" || monId == MonedaPesos {
+		feDetRequest.MonId = MonedaPesos
+		feDetRequest.MonCotiz = 1
+		feDetRequest.CanMisMonExt = "N" // Si informa MonId = PES, el campo CanMisMonExt no debe informarse.
+		return nil
+	}
+
+	canMisMonExt := caeRequest.CanMisMonExt
+	if canMisMonExt == "" {
+		canMisMonExt = "N"
+	}
+	if canMisMonExt != "S" && canMisMonExt != "N" {
+		return fmt.Errorf("CanMisMonExt inválido: %s (valores posibles S o N)", canMisMonExt)
+	}
+
+	monCotiz := caeRequest.MonCotiz
+	if monCotiz <= 0 {
+		if !s.cotizacionAutomatica {
+			return fmt.Errorf("MonCotiz es obligatoria para la moneda %s", monId)
+		}
+
+		var err error
+		monCotiz, err = s.GetCotizacionContext(ctx, cuit, monId, caeRequest.CbteFch)
+		if err != nil {
+			return fmt.Errorf("cotización %s: %w", monId, err)
+		}
+	}
+
+	feDetRequest.MonId = monId
+	feDetRequest.MonCotiz = monCotiz
+	feDetRequest.CanMisMonExt = canMisMonExt
+	return nil
+}
@@ -0,0 +1,41 @@
+package wsfe
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolverMoneda(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   CaeRequest
+		wantMonId string
+		wantCotiz float64
+		wantCan   string
+		wantErr   bool
+	}{
+		{"sin moneda", CaeRequest{}, MonedaPesos, 1, "N", false},
+		{"pesos ignora la cotización", CaeRequest{MonId: MonedaPesos, MonCotiz: 900, CanMisMonExt: "S"}, MonedaPesos, 1, "N", false},
+		{"dólares con cotización", CaeRequest{MonId: MonedaDolares, MonCotiz: 850.5}, MonedaDolares, 850.5, "N", false},
+		{"cancela en la misma moneda", CaeRequest{MonId: MonedaEuros, MonCotiz: 920, CanMisMonExt: "S"}, MonedaEuros, 920, "S", false},
+		{"CanMisMonExt inválido", CaeRequest{MonId: MonedaDolares, MonCotiz: 850.5, CanMisMonExt: "X"}, "", 0, "", true},
+		{"sin cotización ni cotización automática", CaeRequest{MonId: MonedaDolares}, "", 0, "", true},
+	}
+
+	s := &Service{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var det FEDetRequest
+			err := s.resolverMoneda(context.Background(), 20111111112, &tt.request, &det)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolverMoneda() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if det.MonId != tt.wantMonId || det.MonCotiz != tt.wantCotiz || det.CanMisMonExt != tt.wantCan {
+				t.Errorf("resolverMoneda() = %s, %v, %s, want %s, %v, %s", det.MonId, det.MonCotiz, det.CanMisMonExt, tt.wantMonId, tt.wantCotiz, tt.wantCan)
+			}
+		})
+	}
+}
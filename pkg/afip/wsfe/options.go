@@ -0,0 +1,72 @@
+package wsfe
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// Option configura opciones de Service en NewService
+type Option func(*Service)
+
+// WithURL reemplaza la URL del servicio (por ejemplo para un mock local o un proxy)
+func WithURL(url string) Option {
+	return func(s *Service) {
+		s.url = url
+	}
+}
+
+// WithHTTPClient utiliza el cliente HTTP indicado para las llamadas a AFIP.
+// Las opciones WithTLSConfig y WithTransport no se aplican a un cliente propio.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Service) {
+		s.httpClient = client
+	}
+}
+
+// WithTransport utiliza el transport HTTP indicado para las llamadas a AFIP
+func WithTransport(transport http.RoundTripper) Option {
+	return func(s *Service) {
+		s.httpClient = &http.Client{Transport: transport}
+	}
+}
+
+// WithTLSConfig establece la configuración TLS de la conexión con AFIP
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(s *Service) {
+		s.tlsConfig = tlsConfig
+	}
+}
+
+// WithTimeout establece el tiempo de espera por defecto de cada llamada (RequestTimeout).
+// Las llamadas con contexto que ya tiene deadline utilizan el deadline del contexto.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Service) {
+		s.timeout = timeout
+	}
+}
+
+// WithCotizacionAutomatica equivale a SetCotizacionAutomatica(true)
+func WithCotizacionAutomatica() Option {
+	return func(s *Service) {
+		s.cotizacionAutomatica = true
+	}
+}
+
+// WithRecuperacionCAE equivale a SetRecuperacionCAE(true)
+func WithRecuperacionCAE() Option {
+	return func(s *Service) {
+		s.recuperacionCAE = true
+	}
+}
+
+// WithMaxReintentos establece la cantidad de reintentos de Emitir ante errores de numeración.
+// Un valor negativo equivale a 0 (sin reintentos).
+func WithMaxReintentos(reintentos int) Option {
+	return func(s *Service) {
+		if reintentos < 0 {
+			reintentos = 0
+		}
+		s.maxReintentos = reintentos
+	}
+}
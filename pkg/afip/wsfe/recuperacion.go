@@ -0,0 +1,69 @@
+package wsfe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SetRecuperacionCAE habilita la recuperación del CAE cuando FECAESolicitar falla sin respuesta
+// (timeout o error de comunicación). En ese caso se consulta el último comprobante autorizado y,
+// si AFIP ya autorizó el comprobante con los mismos datos, se devuelve ese CAE; si no lo autorizó
+// se reenvía la solicitud.
+func (s *Service) SetRecuperacionCAE(enabled bool) {
+	s.recuperacionCAE = enabled
+}
+
+// esErrorAmbiguo indica si la solicitud pudo haber llegado a AFIP sin que se reciba la respuesta
+func esErrorAmbiguo(err error) bool {
+	var transportErr *TransportError
+	return IsTimeout(err) || errors.As(err, &transportErr)
+}
+
+func coincideComprobante(comprobante *Comprobante, feDetRequest *FEDetRequest) bool {
+	return comprobante.CbteDesde == feDetRequest.CbteDesde &&
+		comprobante.CbteHasta == feDetRequest.CbteHasta &&
+		comprobante.DocTipo == feDetRequest.DocTipo &&
+		comprobante.DocNro == feDetRequest.DocNro &&
+		math.Abs(comprobante.ImpTotal-feDetRequest.ImpTotal) < 0.005
+}
+
+// recuperarCAE determina si AFIP autorizó el comprobante luego de una falla ambigua de FECAESolicitar
+func (s *Service) recuperarCAE(ctx context.Context, cabRequest *CabRequest, feCAEDetRequest *FECAEDetRequest, causa error) (*CaeResult, error) {
+	feDetRequest := feCAEDetRequest.FEDetRequest
+
+	ultimo, err := s.GetUltimoCompContext(ctx, cabRequest)
+	if err != nil {
+		return nil, fmt.Errorf("recuperación de CAE: %w (error original: %s)", err, causa)
+	}
+
+	// El comprobante no fue autorizado, es seguro reenviarlo
+	if int64(ultimo) < feDetRequest.CbteDesde {
+		results, err := s.solicitarLote(ctx, cabRequest, []*FECAEDetRequest{feCAEDetRequest})
+		if err != nil {
+			return nil, err
+		}
+		return results[0], nil
+	}
+
+	comprobante, err := s.ConsultarComprobanteContext(ctx, cabRequest.Cuit, cabRequest.PtoVta, cabRequest.CbteTipo, feDetRequest.CbteDesde)
+	if err != nil {
+		return nil, fmt.Errorf("recuperación de CAE: %w (error original: %s)", err, causa)
+	}
+
+	if !coincideComprobante(comprobante, feDetRequest) {
+		return nil, fmt.Errorf("recuperación de CAE: el comprobante %d ya fue autorizado con otros datos (DocNro %d, ImpTotal %.2f)",
+			feDetRequest.CbteDesde, comprobante.DocNro, comprobante.ImpTotal)
+	}
+
+	return &CaeResult{
+		Resultado:     ResultadoAprobado,
+		FchProceso:    comprobante.FchProceso,
+		CbteDesde:     comprobante.CbteDesde,
+		CbteHasta:     comprobante.CbteHasta,
+		CAE:           comprobante.CodAutorizacion,
+		CAEFchVto:     comprobante.FchVto,
+		Observaciones: comprobante.Observaciones,
+	}, nil
+}
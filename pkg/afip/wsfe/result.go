@@ -0,0 +1,91 @@
+package wsfe
+
+// Valores de Resultado devueltos por AFIP
+const (
+	ResultadoAprobado  = "A"
+	ResultadoRechazado = "R"
+	ResultadoParcial   = "P"
+)
+
+// Mensaje representa un error, observación o evento devuelto por AFIP
+type Mensaje struct {
+	Code int32  `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// CaeResult es el resultado de la solicitud de CAE de un comprobante
+type CaeResult struct {
+	Resultado     string    `json:"resultado"`
+	FchProceso    string    `json:"fchProceso"`
+	CbteDesde     int64     `json:"cbteDesde"`
+	CbteHasta     int64     `json:"cbteHasta"`
+	CAE           string    `json:"cae"`
+	CAEFchVto     string    `json:"caeFchVto"`
+	Errors        []Mensaje `json:"errors,omitempty"`
+	Observaciones []Mensaje `json:"observaciones,omitempty"`
+	Events        []Mensaje `json:"events,omitempty"`
+}
+
+// Aprobado indica si AFIP autorizó el comprobante (con o sin observaciones)
+func (r *CaeResult) Aprobado() bool {
+	return r.Resultado == ResultadoAprobado
+}
+
+// Err devuelve un *AFIPError si AFIP informó errores en la solicitud
+func (r *CaeResult) Err() error {
+	return newAFIPError(r.Errors)
+}
+
+// HasCode indica si AFIP informó alguno de los códigos como error u observación del comprobante.
+// Los rechazos del comprobante (por ejemplo 10016) llegan como observaciones con Resultado R.
+func (r *CaeResult) HasCode(codes ...int32) bool {
+	for _, mensajes := range [][]Mensaje{r.Errors, r.Observaciones} {
+		for _, m := range mensajes {
+			for _, code := range codes {
+				if m.Code == code {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// rechazo devuelve un *AFIPError con los errores y observaciones del comprobante
+func (r *CaeResult) rechazo() error {
+	mensajes := append(append([]Mensaje{}, r.Errors...), r.Observaciones...)
+	return newAFIPError(mensajes)
+}
+
+func mensajesErr(errs *ArrayOfErr) []Mensaje {
+	if errs == nil {
+		return nil
+	}
+	mensajes := make([]Mensaje, 0, len(errs.Err))
+	for _, e := range errs.Err {
+		mensajes = append(mensajes, Mensaje{Code: e.Code, Msg: e.Msg})
+	}
+	return mensajes
+}
+
+func mensajesObs(obs *ArrayOfObs) []Mensaje {
+	if obs == nil {
+		return nil
+	}
+	mensajes := make([]Mensaje, 0, len(obs.Obs))
+	for _, o := range obs.Obs {
+		mensajes = append(mensajes, Mensaje{Code: o.Code, Msg: o.Msg})
+	}
+	return mensajes
+}
+
+func mensajesEvt(evts *ArrayOfEvt) []Mensaje {
+	if evts == nil {
+		return nil
+	}
+	mensajes := make([]Mensaje, 0, len(evts.Evt))
+	for _, e := range evts.Evt {
+		mensajes = append(mensajes, Mensaje{Code: e.Code, Msg: e.Msg})
+	}
+	return mensajes
+}
@@ -1,10 +1,11 @@
 package wsfe
 
 import (
-	"errors"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"math"
-	"net"
+	"net/http"
 	"strconv"
 	"time"
 
@@ -15,13 +16,20 @@ const RequestTimeout = 60 * time.Second
 
 const (
 	FacturaA     = 1
-	NotaCreditoA = 3 
+	NotaCreditoA = 3
 	FacturaB     = 6
 	NotaCreditoB = 8
 	FacturaC     = 11
 	NotaCreditoC = 13
 )
 
+// Conceptos de comprobante
+const (
+	ConceptoProductos          = 1
+	ConceptoServicios          = 2
+	ConceptoProductosServicios = 3
+)
+
 type CabRequest struct {
 	Cuit     int64 `json:"cuit"`
 	PtoVta   int32 `json:"ptoVta"`
@@ -29,18 +37,22 @@ type CabRequest struct {
 }
 
 type CaeRequest struct {
-	DocTipo    int32   `json:"docTipo"`
-	DocNro     int64   `json:"docNro"`
-	CbteDesde  int64   `json:"cbteDesde"`
-	CbteHasta  int64   `json:"cbteHasta"`
-	CbteFch    string  `json:"cbteFch"`
-	ImpNeto    float64 `json:"impNeto"`
-	ImpOpEx    float64 `json:"impOpEx"`
-	ImpTotConc float64 `json:"impTotConc"`
-	ImpTotal   float64 `json:"impTotal"`
-	ImpTrib    float64 `json:"impTrib"`
-	ImpIVA     float64 `json:"impIVA"`
-	IvasArray  []struct {
+	Concepto     int32   `json:"concepto"`
+	DocTipo      int32   `json:"docTipo"`
+	DocNro       int64   `json:"docNro"`
+	CbteDesde    int64   `json:"cbteDesde"`
+	CbteHasta    int64   `json:"cbteHasta"`
+	CbteFch      string  `json:"cbteFch"`
+	FchServDesde string  `json:"fchServDesde"`
+	FchServHasta string  `json:"fchServHasta"`
+	FchVtoPago   string  `json:"fchVtoPago"`
+	ImpNeto      float64 `json:"impNeto"`
+	ImpOpEx      float64 `json:"impOpEx"`
+	ImpTotConc   float64 `json:"impTotConc"`
+	ImpTotal     float64 `json:"impTotal"`
+	ImpTrib      float64 `json:"impTrib"`
+	ImpIVA       float64 `json:"impIVA"`
+	IvasArray    []struct {
 		ID      int32   `json:"id"`
 		BaseImp float64 `json:"baseImp"`
 		Importe float64 `json:"importe"`
@@ -52,10 +64,12 @@ type CaeRequest struct {
 		Alic    float64 `json:"Alic"`
 		Importe float64 `json:"importe"`
 	} `json:"tributosArray"`
-	CbteTipoRef int32 `json:"cbteTipoRef"`
-	CbteNroRef  int64 `json:"cbteNroRef"`
-	CanMisMonExt string `json:"canMisMonExt"`
-	CondicionIVAReceptorId int32 `json:"condicionIVAReceptorId"`
+	CbteTipoRef            int32   `json:"cbteTipoRef"`
+	CbteNroRef             int64   `json:"cbteNroRef"`
+	MonId                  string  `json:"monId"`
+	MonCotiz               float64 `json:"monCotiz"`
+	CanMisMonExt           string  `json:"canMisMonExt"`
+	CondicionIVAReceptorId int32   `json:"condicionIVAReceptorId"`
 }
 
 const URLWSAATesting string = "https://wswhomo.afip.gov.ar/wsfev1/service.asmx?wsdl"
@@ -76,15 +90,24 @@ type Service struct {
 	serviceSoap ServiceSoap
 	token       string
 	sign        string
+	credentials CredentialsProvider
+	url         string
+	timeout     time.Duration
+	httpClient  *http.Client
+	tlsConfig   *tls.Config
+
+	cotizacionAutomatica bool
+	recuperacionCAE      bool
+	maxReintentos        int
 }
 
 func BankersRounding(f float64) float64 {
-    str := fmt.Sprintf("%.3f", f)
-    f, _ = strconv.ParseFloat(str, 64)
-    return math.Round(f*100) / 100
+	str := fmt.Sprintf("%.3f", f)
+	f, _ = strconv.ParseFloat(str, 64)
+	return math.Round(f*100) / 100
 }
 
-func NewService(environment Environment, token, sign string) *Service {
+func NewService(environment Environment, token, sign string, opts ...Option) *Service {
 	var url string
 	if environment == PRODUCTION {
 		url = URLWSAAProduction
@@ -92,59 +115,115 @@ func NewService(environment Environment, token, sign string) *Service {
 		url = URLWSAATesting
 	}
 
-	soapClient := soap.NewClient(url, soap.WithTimeout(RequestTimeout), soap.WithRequestTimeout(RequestTimeout))
-	serviceSoap := NewServiceSoap(soapClient)
+	s := &Service{environment: environment, token: token, sign: sign, url: url, timeout: RequestTimeout, maxReintentos: MaxReintentosEmision}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// El timeout total lo decide el deadline del contexto (ver withTimeout); sin WithRequestTimeout
+	// gowsdl cortaría toda llamada a los 90 segundos
+	soapOptions := []soap.Option{soap.WithTimeout(s.timeout), soap.WithRequestTimeout(0)}
+	if s.httpClient != nil {
+		soapOptions = []soap.Option{soap.WithHTTPClient(s.httpClient)}
+	} else if s.tlsConfig != nil {
+		soapOptions = append(soapOptions, soap.WithTLS(s.tlsConfig))
+	}
+
+	soapClient := soap.NewClient(s.url, soapOptions...)
+	s.serviceSoap = NewServiceSoap(soapClient)
 
-	return &Service{environment: environment, serviceSoap: serviceSoap, token: token, sign: sign}
+	return s
 }
 
-func (s *Service) getAuth(cuit int64) *FEAuthRequest {
+func (s *Service) getAuth(ctx context.Context, cuit int64, rejectedToken string) (*FEAuthRequest, error) {
+	token, sign := s.token, s.sign
+	if s.credentials != nil {
+		var err error
+		token, sign, err = s.credentials.Credentials(ctx, rejectedToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	feAuthRequest := FEAuthRequest{
-		Token: s.token,
-		Sign:  s.sign,
+		Token: token,
+		Sign:  sign,
 		Cuit:  cuit,
 	}
-	return &feAuthRequest
+	return &feAuthRequest, nil
 }
 
-func isTimeoutError(err error) bool {
-	var netErr net.Error
-	if errors.As(err, &netErr) && netErr.Timeout() {
-		return true
+// conAuth ejecuta la llamada con las credenciales vigentes. Si AFIP rechaza el token (600)
+// y el servicio tiene un CredentialsProvider, la reintenta una vez con credenciales renovadas.
+func (s *Service) conAuth(ctx context.Context, cuit int64, call func(auth *FEAuthRequest) error) error {
+	auth, err := s.getAuth(ctx, cuit, "")
+	if err != nil {
+		return err
+	}
+
+	err = call(auth)
+	if s.credentials == nil || !IsAuthExpired(err) {
+		return err
 	}
-	return false
+
+	auth, err = s.getAuth(ctx, cuit, auth.Token)
+	if err != nil {
+		return err
+	}
+	return call(auth)
+}
+
+// withTimeout aplica el timeout del servicio a la llamada si el contexto no tiene un deadline propio
+// y devuelve el tiempo de espera efectivo.
+func (s *Service) withTimeout(ctx context.Context) (context.Context, context.CancelFunc, time.Duration) {
+	if deadline, ok := ctx.Deadline(); ok {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, time.Until(deadline)
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	return ctx, cancel, s.timeout
 }
 
 func (s *Service) GetUltimoComp(cabRequest *CabRequest) (int32, error) {
+	return s.GetUltimoCompContext(context.Background(), cabRequest)
+}
+
+// GetUltimoCompContext es como GetUltimoComp pero permite cancelar la llamada o fijar su timeout con ctx
+func (s *Service) GetUltimoCompContext(ctx context.Context, cabRequest *CabRequest) (int32, error) {
 	feCompUltimoAutorizado := FECompUltimoAutorizado{
-		Auth:     s.getAuth(cabRequest.Cuit),
 		PtoVta:   cabRequest.PtoVta,
 		CbteTipo: cabRequest.CbteTipo,
 	}
 
-	feCompUltimoAutorizadoResponse, err := s.serviceSoap.FECompUltimoAutorizado(&feCompUltimoAutorizado)
-	if err != nil {
-		if isTimeoutError(err) {
-			return -1, fmt.Errorf("timeout: el servicio AFIP no respondió en %s", RequestTimeout)
+	ctx, cancel, timeout := s.withTimeout(ctx)
+	defer cancel()
+
+	var result *FERecuperaLastCbteResponse
+	err := s.conAuth(ctx, cabRequest.Cuit, func(auth *FEAuthRequest) error {
+		feCompUltimoAutorizado.Auth = auth
+		feCompUltimoAutorizadoResponse, err := s.serviceSoap.FECompUltimoAutorizadoContext(ctx, &feCompUltimoAutorizado)
+		if err != nil {
+			return wrapSoapError(err, timeout)
 		}
-		return -1, err
-	}
 
-	result := feCompUltimoAutorizadoResponse.FECompUltimoAutorizadoResult
-	if result.Errors != nil && len(result.Errors.Err) > 0 {
-		return -1, fmt.Errorf("error AFIP (código %d): %s", result.Errors.Err[0].Code, result.Errors.Err[0].Msg)
+		result = feCompUltimoAutorizadoResponse.FECompUltimoAutorizadoResult
+		return newAFIPError(mensajesErr(result.Errors))
+	})
+	if err != nil {
+		return -1, err
 	}
 
 	return result.CbteNro, nil
 }
 
-func (s *Service) CaeRequest(cabRequest *CabRequest, caeRequest *CaeRequest) (string, string, error) {
-	feCAECabRequest := FECAECabRequest{
-		FECabRequest: &FECabRequest{
-			CantReg:  1,
-			PtoVta:   cabRequest.PtoVta,
-			CbteTipo: cabRequest.CbteTipo,
-		},
+func (s *Service) buildDetRequest(ctx context.Context, cabRequest *CabRequest, caeRequest *CaeRequest) (*FECAEDetRequest, error) {
+	concepto := caeRequest.Concepto
+	if concepto == 0 {
+		concepto = ConceptoProductos
+	}
+
+	if err := validarFechas(cabRequest.CbteTipo, concepto, caeRequest, time.Now()); err != nil {
+		return nil, err
 	}
 
 	ivas := make([]*AlicIva, 0)
@@ -163,7 +242,7 @@ func (s *Service) CaeRequest(cabRequest *CabRequest, caeRequest *CaeRequest) (st
 
 	//body request
 	feDetRequest := FEDetRequest{
-		Concepto:               1,
+		Concepto:               concepto,
 		DocTipo:                caeRequest.DocTipo,
 		DocNro:                 caeRequest.DocNro,
 		CbteDesde:              caeRequest.CbteDesde,
@@ -175,13 +254,14 @@ func (s *Service) CaeRequest(cabRequest *CabRequest, caeRequest *CaeRequest) (st
 		ImpOpEx:                BankersRounding(caeRequest.ImpOpEx),
 		ImpTrib:                BankersRounding(caeRequest.ImpTrib),
 		ImpIVA:                 BankersRounding(caeRequest.ImpIVA),
-		MonId:                  "PES",
-		CanMisMonExt:           "N",  // Si informa MonId = PES, el campo CanMisMonExt no debe informarse.
 		CondicionIVAReceptorId: caeRequest.CondicionIVAReceptorId,
-		MonCotiz:               1,
-		FchVtoPago:             "",
-		FchServDesde:           "",
-		FchServHasta:           "",
+		FchVtoPago:             caeRequest.FchVtoPago,
+		FchServDesde:           caeRequest.FchServDesde,
+		FchServHasta:           caeRequest.FchServHasta,
+	}
+
+	if err := s.resolverMoneda(ctx, cabRequest.Cuit, caeRequest, &feDetRequest); err != nil {
+		return nil, err
 	}
 
 	if cabRequest.CbteTipo != FacturaC && cabRequest.CbteTipo != NotaCreditoC &&
@@ -231,46 +311,54 @@ func (s *Service) CaeRequest(cabRequest *CabRequest, caeRequest *CaeRequest) (st
 		feDetRequest.Tributos = &arrayOfTributo
 	}
 
-	feCAEDetRequest := FECAEDetRequest{
-		&feDetRequest,
-	}
-	arrayOfFECAEDetRequest := ArrayOfFECAEDetRequest{
-		FECAEDetRequest: []*FECAEDetRequest{&feCAEDetRequest},
-	}
+	return &FECAEDetRequest{&feDetRequest}, nil
+}
 
-	feCAERequest := FECAERequest{
-		FeCabReq: &feCAECabRequest,
-		FeDetReq: &arrayOfFECAEDetRequest,
-	}
+// SolicitarCAE solicita el CAE de un comprobante y devuelve el resultado completo de AFIP.
+// Solo devuelve error si la solicitud no pudo realizarse, los rechazos se informan en el resultado.
+func (s *Service) SolicitarCAE(cabRequest *CabRequest, caeRequest *CaeRequest) (*CaeResult, error) {
+	return s.SolicitarCAEContext(context.Background(), cabRequest, caeRequest)
+}
 
-	// solicitar cae request
-	feCaeSolicitar := FECAESolicitar{
-		Auth:     s.getAuth(cabRequest.Cuit),
-		FeCAEReq: &feCAERequest,
+// SolicitarCAEContext es como SolicitarCAE pero permite cancelar la llamada o fijar su timeout con ctx
+func (s *Service) SolicitarCAEContext(ctx context.Context, cabRequest *CabRequest, caeRequest *CaeRequest) (*CaeResult, error) {
+	feCAEDetRequest, err := s.buildDetRequest(ctx, cabRequest, caeRequest)
+	if err != nil {
+		return nil, err
 	}
 
-	feCAESolicitarResponse, err := s.serviceSoap.FECAESolicitar(&feCaeSolicitar)
+	results, err := s.solicitarLote(ctx, cabRequest, []*FECAEDetRequest{feCAEDetRequest})
 	if err != nil {
-		if isTimeoutError(err) {
-			return "", "", fmt.Errorf("timeout: el servicio AFIP no respondió en %s", RequestTimeout)
+		if s.recuperacionCAE && esErrorAmbiguo(err) {
+			return s.recuperarCAE(ctx, cabRequest, feCAEDetRequest, err)
 		}
-		return "", "", err
+		return nil, err
 	}
 
-	feCAESolicitarResult := feCAESolicitarResponse.FECAESolicitarResult
-	if feCAESolicitarResult.Errors != nil {
-		return "", "", fmt.Errorf(feCAESolicitarResult.Errors.Err[0].Msg)
+	return results[0], nil
+}
+
+// CaeRequest solicita el CAE de un comprobante y devuelve el CAE y su fecha de vencimiento.
+// Si AFIP informa observaciones se devuelve el CAE junto con un *AFIPError con las observaciones.
+func (s *Service) CaeRequest(cabRequest *CabRequest, caeRequest *CaeRequest) (string, string, error) {
+	return s.CaeRequestContext(context.Background(), cabRequest, caeRequest)
+}
+
+// CaeRequestContext es como CaeRequest pero permite cancelar la llamada o fijar su timeout con ctx
+func (s *Service) CaeRequestContext(ctx context.Context, cabRequest *CabRequest, caeRequest *CaeRequest) (string, string, error) {
+	result, err := s.SolicitarCAEContext(ctx, cabRequest, caeRequest)
+	if err != nil {
+		return "", "", err
 	}
 
-	feDetResponse := feCAESolicitarResult.FeDetResp
-	cae := feDetResponse.FECAEDetResponse[0].CAE
-	caeFchVto := feDetResponse.FECAEDetResponse[0].CAEFchVto
+	if err := result.Err(); err != nil {
+		return "", "", err
+	}
 
-	if len(feDetResponse.FECAEDetResponse) > 0 {
-		if feDetResponse.FECAEDetResponse[0].Observaciones != nil {
-			return cae, caeFchVto, fmt.Errorf(feDetResponse.FECAEDetResponse[0].Observaciones.Obs[0].Msg)
-		}
+	// Las observaciones conservan su código (por ejemplo 10016, ver IsOutOfSequence)
+	if err := newAFIPError(result.Observaciones); err != nil {
+		return result.CAE, result.CAEFchVto, err
 	}
 
-	return cae, caeFchVto, nil
+	return result.CAE, result.CAEFchVto, nil
 }
@@ -0,0 +1,183 @@
+package wsfe
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeWSFE responde a cada operación (FECAESolicitar, FECompUltimoAutorizado, ...) con lo que
+// devuelve respond. call es el número de llamada a la operación, comenzando en 1.
+type fakeWSFE struct {
+	mu      sync.Mutex
+	calls   map[string]int
+	respond func(operation string, call int, w http.ResponseWriter)
+}
+
+func (f *fakeWSFE) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	operation := path.Base(strings.Trim(r.Header.Get("SOAPAction"), `"`))
+
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.calls[operation]++
+	call := f.calls[operation]
+	f.mu.Unlock()
+
+	f.respond(operation, call, w)
+}
+
+func (f *fakeWSFE) count(operation string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[operation]
+}
+
+func writeSoap(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>%s</soap:Body></soap:Envelope>`, body)
+}
+
+func writeUltimoComp(w http.ResponseWriter, cbteNro int32) {
+	writeSoap(w, fmt.Sprintf(`<FECompUltimoAutorizadoResponse xmlns="http://ar.gov.afip.dif.FEV1/"><FECompUltimoAutorizadoResult><PtoVta>1</PtoVta><CbteTipo>11</CbteTipo><CbteNro>%d</CbteNro></FECompUltimoAutorizadoResult></FECompUltimoAutorizadoResponse>`, cbteNro))
+}
+
+func writeCAE(w http.ResponseWriter, cbteNro int64, cae string) {
+	writeSoap(w, fmt.Sprintf(`<FECAESolicitarResponse xmlns="http://ar.gov.afip.dif.FEV1/"><FECAESolicitarResult><FeCabResp><Cuit>20111111112</Cuit><PtoVta>1</PtoVta><CbteTipo>11</CbteTipo><FchProceso>20240315</FchProceso><CantReg>1</CantReg><Resultado>A</Resultado></FeCabResp><FeDetResp><FECAEDetResponse><Concepto>1</Concepto><DocTipo>99</DocTipo><DocNro>0</DocNro><CbteDesde>%[1]d</CbteDesde><CbteHasta>%[1]d</CbteHasta><Resultado>A</Resultado><CAE>%[2]s</CAE><CAEFchVto>20240325</CAEFchVto></FECAEDetResponse></FeDetResp></FECAESolicitarResult></FECAESolicitarResponse>`, cbteNro, cae))
+}
+
+func writeComprobante(w http.ResponseWriter, cbteNro int64, impTotal float64, cae string) {
+	writeSoap(w, fmt.Sprintf(`<FECompConsultarResponse xmlns="http://ar.gov.afip.dif.FEV1/"><FECompConsultarResult><ResultGet><Concepto>1</Concepto><DocTipo>99</DocTipo><DocNro>0</DocNro><CbteDesde>%[1]d</CbteDesde><CbteHasta>%[1]d</CbteHasta><ImpTotal>%[2]v</ImpTotal><Resultado>A</Resultado><CodAutorizacion>%[3]s</CodAutorizacion><EmisionTipo>CAE</EmisionTipo><FchVto>20240325</FchVto><FchProceso>20240315</FchProceso><PtoVta>1</PtoVta><CbteTipo>11</CbteTipo></ResultGet></FECompConsultarResult></FECompConsultarResponse>`, cbteNro, impTotal, cae))
+}
+
+func writeErr(w http.ResponseWriter, operation string, code int32, msg string) {
+	writeSoap(w, fmt.Sprintf(`<%[1]sResponse xmlns="http://ar.gov.afip.dif.FEV1/"><%[1]sResult><Errors><Err><Code>%[2]d</Code><Msg>%[3]s</Msg></Err></Errors></%[1]sResult></%[1]sResponse>`, operation, code, msg))
+}
+
+// closeConnection corta la conexión sin responder, como cuando se pierde la respuesta de AFIP
+func closeConnection(t *testing.T, w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conn.Close()
+}
+
+func TestGetUltimoComp(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(w http.ResponseWriter)
+		want    int32
+		check   func(error) bool
+	}{
+		{"último comprobante", func(w http.ResponseWriter) { writeUltimoComp(w, 41) }, 41, nil},
+		{"token vencido", func(w http.ResponseWriter) {
+			writeErr(w, "FECompUltimoAutorizado", ErrCodeValidacionToken, "token vencido")
+		}, -1, IsAuthExpired},
+		{"cuit no representada", func(w http.ResponseWriter) {
+			writeErr(w, "FECompUltimoAutorizado", ErrCodeCuitNoRepresentada, "cuit no representada")
+		}, -1, IsCuitNoRepresentada},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wsfe := &fakeWSFE{respond: func(operation string, call int, w http.ResponseWriter) { tt.respond(w) }}
+			server := httptest.NewServer(wsfe)
+			defer server.Close()
+
+			s := NewService(TESTING, "TOKEN", "SIGN", WithURL(server.URL))
+			got, err := s.GetUltimoComp(&CabRequest{Cuit: 20111111112, PtoVta: 1, CbteTipo: FacturaC})
+			if tt.check == nil && err != nil {
+				t.Fatalf("GetUltimoComp() error = %v", err)
+			}
+			if tt.check != nil && !tt.check(err) {
+				t.Fatalf("GetUltimoComp() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetUltimoComp() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSolicitarCAERecuperacion(t *testing.T) {
+	tests := []struct {
+		name string
+		// respuestas de FECompUltimoAutorizado y FECompConsultar luego de perder la respuesta
+		ultimo      int32
+		impTotal    float64
+		wantCAE     string
+		wantErr     bool
+		wantEnvios  int
+		wantConsult int
+	}{
+		{"autorizado antes del corte", 5, 100, "74000000000001", false, 1, 1},
+		{"no autorizado, se reenvía", 4, 0, "74000000000002", false, 2, 0},
+		{"autorizado con otros datos", 5, 250, "", true, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wsfe := &fakeWSFE{}
+			wsfe.respond = func(operation string, call int, w http.ResponseWriter) {
+				switch operation {
+				case "FECAESolicitar":
+					if call == 1 {
+						closeConnection(t, w)
+						return
+					}
+					writeCAE(w, 5, "74000000000002")
+				case "FECompUltimoAutorizado":
+					writeUltimoComp(w, tt.ultimo)
+				case "FECompConsultar":
+					writeComprobante(w, 5, tt.impTotal, "74000000000001")
+				default:
+					t.Errorf("unexpected operation %s", operation)
+				}
+			}
+			server := httptest.NewServer(wsfe)
+			defer server.Close()
+
+			s := NewService(TESTING, "TOKEN", "SIGN", WithURL(server.URL), WithHTTPClient(server.Client()), WithRecuperacionCAE())
+			result, err := s.SolicitarCAE(&CabRequest{Cuit: 20111111112, PtoVta: 1, CbteTipo: FacturaC},
+				&CaeRequest{DocTipo: 99, CbteDesde: 5, CbteHasta: 5, ImpTotal: 100, ImpNeto: 100})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SolicitarCAE() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (result.CAE != tt.wantCAE || result.Resultado != ResultadoAprobado) {
+				t.Errorf("SolicitarCAE() = %s %s, want %s A", result.CAE, result.Resultado, tt.wantCAE)
+			}
+			if got := wsfe.count("FECAESolicitar"); got != tt.wantEnvios {
+				t.Errorf("FECAESolicitar called %d times, want %d", got, tt.wantEnvios)
+			}
+			if got := wsfe.count("FECompConsultar"); got != tt.wantConsult {
+				t.Errorf("FECompConsultar called %d times, want %d", got, tt.wantConsult)
+			}
+		})
+	}
+}
+
+func TestSolicitarCAESinRecuperacion(t *testing.T) {
+	wsfe := &fakeWSFE{respond: func(operation string, call int, w http.ResponseWriter) {
+		closeConnection(t, w)
+	}}
+	server := httptest.NewServer(wsfe)
+	defer server.Close()
+
+	// Sin WithRecuperacionCAE el error se devuelve al llamador y no se reenvía
+	s := NewService(TESTING, "TOKEN", "SIGN", WithURL(server.URL))
+	_, err := s.SolicitarCAE(&CabRequest{Cuit: 20111111112, PtoVta: 1, CbteTipo: FacturaC},
+		&CaeRequest{DocTipo: 99, CbteDesde: 5, CbteHasta: 5, ImpTotal: 100, ImpNeto: 100})
+	if !esErrorAmbiguo(err) {
+		t.Fatalf("SolicitarCAE() error = %v, want a transport error", err)
+	}
+	if got := wsfe.count("FECAESolicitar"); got != 1 {
+		t.Errorf("FECAESolicitar called %d times, want 1", got)
+	}
+}
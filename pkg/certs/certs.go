@@ -1,22 +1,75 @@
 package certs
 
 import (
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 
-	"go.mozilla.org/pkcs7"
+	"github.com/smallstep/pkcs7"
 )
 
-func EncodeCMS(content []byte, certificate *x509.Certificate, privateKey *rsa.PrivateKey) ([]byte, error) {
+// CMSOption configura la firma CMS de EncodeCMS
+type CMSOption func(*cmsConfig)
+
+type cmsConfig struct {
+	digest crypto.Hash
+	chain  []*x509.Certificate
+}
+
+// WithDigest establece el algoritmo de digest de la firma (por defecto SHA-256).
+// Se admiten crypto.SHA1, crypto.SHA256, crypto.SHA384 y crypto.SHA512.
+func WithDigest(digest crypto.Hash) CMSOption {
+	return func(c *cmsConfig) {
+		c.digest = digest
+	}
+}
+
+// WithChain incluye en el CMS los certificados intermedios, comenzando por el emisor del certificado firmante
+func WithChain(chain ...*x509.Certificate) CMSOption {
+	return func(c *cmsConfig) {
+		c.chain = chain
+	}
+}
+
+func digestOID(digest crypto.Hash) (asn1.ObjectIdentifier, error) {
+	switch digest {
+	case crypto.SHA1:
+		return pkcs7.OIDDigestAlgorithmSHA1, nil
+	case crypto.SHA256:
+		return pkcs7.OIDDigestAlgorithmSHA256, nil
+	case crypto.SHA384:
+		return pkcs7.OIDDigestAlgorithmSHA384, nil
+	case crypto.SHA512:
+		return pkcs7.OIDDigestAlgorithmSHA512, nil
+	}
+	return nil, fmt.Errorf("unsupported digest algorithm %s", digest)
+}
+
+// EncodeCMS firma content con el certificado y la clave indicados. signer puede ser una clave RSA o
+// ECDSA en memoria o cualquier crypto.Signer (por ejemplo una clave en un HSM o en el almacén del sistema).
+func EncodeCMS(content []byte, certificate *x509.Certificate, signer crypto.Signer, opts ...CMSOption) ([]byte, error) {
+	config := cmsConfig{digest: crypto.SHA256}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	oid, err := digestOID(config.digest)
+	if err != nil {
+		return nil, fmt.Errorf("encodeCMS: %s", err)
+	}
+
 	signedData, err := pkcs7.NewSignedData(content)
 	if err != nil {
 		return nil, fmt.Errorf("encodeCMS: failied to initialize SignedData. %s", err)
 	}
+	signedData.SetDigestAlgorithm(oid)
 
-	if err := signedData.AddSigner(certificate, privateKey, pkcs7.SignerInfoConfig{}); err != nil {
+	if err := signedData.AddSignerChain(certificate, signer, config.chain, pkcs7.SignerInfoConfig{}); err != nil {
 		return nil, fmt.Errorf("encodeCMS: unable to add signer: %s", err)
 	}
 
@@ -28,7 +81,12 @@ func EncodeCMS(content []byte, certificate *x509.Certificate, privateKey *rsa.Pr
 	return detachedSignature, nil
 }
 
-func LoadX509KeyPair(certFile, keyFile string) (*x509.Certificate, *rsa.PrivateKey, error) {
+// LoadX509KeyPair lee el certificado y la clave privada (RSA o ECDSA) en formato PEM
+func LoadX509KeyPair(certFile, keyFile string) (*x509.Certificate, crypto.Signer, error) {
+	return loadX509KeyPair(certFile, keyFile, nil)
+}
+
+func loadX509KeyPair(certFile, keyFile string, password []byte) (*x509.Certificate, crypto.Signer, error) {
 	certData, err := ioutil.ReadFile(certFile)
 	if err != nil {
 		return nil, nil, fmt.Errorf("LoadX509KeyPair: crt file not found: %s", err)
@@ -39,37 +97,73 @@ func LoadX509KeyPair(certFile, keyFile string) (*x509.Certificate, *rsa.PrivateK
 		return nil, nil, fmt.Errorf("LoadX509KeyPair: key file not found: %s", err)
 	}
 
-	certDecode, _ := pem.Decode(certData)
-	if certData == nil {
-		return nil, nil, fmt.Errorf("LoadX509KeyPair: could not decode crt data: %s", err)
+	crt, key, err := parseX509KeyPair(certData, keyData, password)
+	if err != nil {
+		return nil, nil, fmt.Errorf("LoadX509KeyPair: %s", err)
 	}
-	keyDecode, _ := pem.Decode(keyData)
+	return crt, key, nil
+}
+
+// ParseX509KeyPair es como LoadX509KeyPair pero recibe el contenido PEM del certificado y la clave
+func ParseX509KeyPair(certPEM, keyPEM []byte) (*x509.Certificate, crypto.Signer, error) {
+	crt, key, err := parseX509KeyPair(certPEM, keyPEM, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ParseX509KeyPair: %s", err)
+	}
+	return crt, key, nil
+}
+
+func parseX509KeyPair(certPEM, keyPEM, password []byte) (*x509.Certificate, crypto.Signer, error) {
+	certDecode, _ := pem.Decode(certPEM)
+	if certDecode == nil {
+		return nil, nil, fmt.Errorf("could not decode crt data")
+	}
+	keyDecode, _ := pem.Decode(keyPEM)
 	if keyDecode == nil {
-		return nil, nil, fmt.Errorf("LoadX509KeyPair: could not decode key data: %s", err)
+		return nil, nil, fmt.Errorf("could not decode key data")
 	}
 
 	crt, err := x509.ParseCertificate(certDecode.Bytes)
 	if err != nil {
-		return nil, nil, fmt.Errorf("LoadX509KeyPair: could not parse crt data: %s", err)
+		return nil, nil, fmt.Errorf("could not parse crt data: %s", err)
 	}
 
-    var key *rsa.PrivateKey
+	key, err := parseKeyBlock(keyDecode, password)
+	if err != nil {
+		return nil, nil, err
+	}
 
-    // Try PKCS#1 (old format)
-    key, err = x509.ParsePKCS1PrivateKey(keyDecode.Bytes)
-    if err == nil {
-        return crt, key, nil
-    }
+	return crt, key, nil
+}
 
-    // Try PKCS#8 (new format)
-    parsedKey, err := x509.ParsePKCS8PrivateKey(keyDecode.Bytes)
-    if err == nil {
-        if rsaKey, ok := parsedKey.(*rsa.PrivateKey); ok {
-            return crt, rsaKey, nil
-        }
-        return nil, nil, fmt.Errorf("LoadX509KeyPair: key is not RSA")
-    }
+// parsePrivateKey interpreta una clave privada DER en formato PKCS#1, SEC 1 (EC) o PKCS#8
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	// Try PKCS#1 (old format)
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	// Try SEC 1 (EC PRIVATE KEY)
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
 
-    // Si falla todo, devolver error
-    return nil, nil, fmt.Errorf("LoadX509KeyPair: unsupported key format: %s", err)
+	// Try PKCS#8 (new format)
+	parsedKey, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported key format: %s", err)
+	}
+
+	return signerKey(parsedKey)
+}
+
+// signerKey devuelve la clave como crypto.Signer si es de un tipo soportado (RSA o ECDSA)
+func signerKey(key interface{}) (crypto.Signer, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
 }
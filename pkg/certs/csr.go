@@ -0,0 +1,194 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultKeyBits es el tamaño de clave RSA por defecto de GenerateKey
+const DefaultKeyBits = 2048
+
+// Errores de ValidateCertificate
+var (
+	ErrKeyMismatch     = errors.New("certificate does not match private key")
+	ErrCUITMismatch    = errors.New("certificate serialNumber does not match CUIT")
+	ErrCertNotYetValid = errors.New("certificate is not yet valid")
+	ErrCertExpired     = errors.New("certificate has expired")
+)
+
+// ValidCUIT verifica el dígito verificador de un CUIT/CUIL
+func ValidCUIT(cuit int64) bool {
+	digits := strconv.FormatInt(cuit, 10)
+	if len(digits) != 11 {
+		return false
+	}
+
+	weights := []int{5, 4, 3, 2, 7, 6, 5, 4, 3, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(digits[i]-'0') * w
+	}
+
+	check := 11 - sum%11
+	switch check {
+	case 11:
+		check = 0
+	case 10:
+		check = 9
+	}
+	return check == int(digits[10]-'0')
+}
+
+// GenerateKey genera una clave RSA de bits bits (DefaultKeyBits si es 0) y la guarda en keyFile
+// en formato PEM PKCS#1 con permisos 0600. Devuelve un error si keyFile ya existe.
+func GenerateKey(keyFile string, bits int) (*rsa.PrivateKey, error) {
+	if bits == 0 {
+		bits = DefaultKeyBits
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, fmt.Errorf("GenerateKey: %s", err)
+	}
+
+	// Nunca se sobrescribe una clave existente: el certificado emitido por AFIP depende de ella
+	file, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("GenerateKey: %s", err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if _, err := file.Write(keyPEM); err != nil {
+		file.Close()
+		os.Remove(keyFile)
+		return nil, fmt.Errorf("GenerateKey: %s", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(keyFile)
+		return nil, fmt.Errorf("GenerateKey: %s", err)
+	}
+
+	return key, nil
+}
+
+// CreateCSR devuelve en formato PEM el pedido de certificado que requiere AFIP:
+// C=AR, O=<razón social>, CN=<alias>, serialNumber=CUIT <cuit>
+func CreateCSR(key crypto.Signer, cuit int64, org, alias string) ([]byte, error) {
+	if !ValidCUIT(cuit) {
+		return nil, fmt.Errorf("CreateCSR: invalid CUIT %d", cuit)
+	}
+	if strings.TrimSpace(org) == "" || strings.TrimSpace(alias) == "" {
+		return nil, fmt.Errorf("CreateCSR: organization and alias are required")
+	}
+
+	template := x509.CertificateRequest{
+		Subject: pkix.Name{
+			Country:      []string{"AR"},
+			Organization: []string{org},
+			CommonName:   alias,
+			SerialNumber: fmt.Sprintf("CUIT %d", cuit),
+		},
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &template, key)
+	if err != nil {
+		return nil, fmt.Errorf("CreateCSR: %s", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}), nil
+}
+
+// GenerateCSR crea el pedido de certificado para la clave de keyFile y lo guarda en csrFile
+func GenerateCSR(csrFile, keyFile string, cuit int64, org, alias string) error {
+	keyData, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("GenerateCSR: key file not found: %s", err)
+	}
+
+	keyDecode, _ := pem.Decode(keyData)
+	if keyDecode == nil {
+		return fmt.Errorf("GenerateCSR: could not decode key data")
+	}
+
+	key, err := parsePrivateKey(keyDecode.Bytes)
+	if err != nil {
+		return fmt.Errorf("GenerateCSR: %s", err)
+	}
+
+	csr, err := CreateCSR(key, cuit, org, alias)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(csrFile, csr, 0644); err != nil {
+		return fmt.Errorf("GenerateCSR: %s", err)
+	}
+	return nil
+}
+
+// CUIT devuelve el CUIT del serialNumber del certificado ("CUIT 20123456789")
+func CUIT(crt *x509.Certificate) (int64, error) {
+	serial := strings.TrimSpace(crt.Subject.SerialNumber)
+	if !strings.HasPrefix(serial, "CUIT ") {
+		return 0, fmt.Errorf("certificate serialNumber %q is not a CUIT", serial)
+	}
+
+	cuit, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(serial, "CUIT ")), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("certificate serialNumber %q is not a CUIT", serial)
+	}
+	return cuit, nil
+}
+
+// ValidateCertificate verifica que el certificado corresponda a la clave privada, que esté vigente
+// y, si cuit no es 0, que su serialNumber sea el CUIT indicado
+func ValidateCertificate(crt *x509.Certificate, key crypto.Signer, cuit int64) error {
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(crt.PublicKey) {
+		return ErrKeyMismatch
+	}
+
+	now := time.Now()
+	if now.Before(crt.NotBefore) {
+		return ErrCertNotYetValid
+	}
+	if now.After(crt.NotAfter) {
+		return ErrCertExpired
+	}
+
+	if cuit != 0 {
+		certCUIT, err := CUIT(crt)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrCUITMismatch, err)
+		}
+		if certCUIT != cuit {
+			return fmt.Errorf("%w: certificate is for CUIT %d, expected %d", ErrCUITMismatch, certCUIT, cuit)
+		}
+	}
+
+	return nil
+}
+
+// ValidateKeyPair carga el certificado y la clave de los archivos indicados y los valida con ValidateCertificate
+func ValidateKeyPair(certFile, keyFile string, cuit int64) error {
+	crt, key, err := LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	if err := ValidateCertificate(crt, key, cuit); err != nil {
+		return fmt.Errorf("ValidateKeyPair: %w", err)
+	}
+	return nil
+}
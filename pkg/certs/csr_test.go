@@ -0,0 +1,108 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidCUIT(t *testing.T) {
+	tests := []struct {
+		cuit int64
+		want bool
+	}{
+		{20285142084, true},
+		{30500010912, true},
+		{20111111112, true},
+		{27000000006, true},
+		{20285142085, false},
+		{2028514208, false},
+		{202851420840, false},
+		{0, false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidCUIT(tt.cuit); got != tt.want {
+			t.Errorf("ValidCUIT(%d) = %v, want %v", tt.cuit, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateKeyDoesNotOverwrite(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "cert.key")
+	if err := ioutil.WriteFile(keyFile, []byte("existing"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GenerateKey(keyFile, 1024); err == nil {
+		t.Fatal("GenerateKey() overwrote an existing key file")
+	}
+
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil || string(data) != "existing" {
+		t.Errorf("key file was modified: %q, %v", data, err)
+	}
+}
+
+func TestValidateCertificate(t *testing.T) {
+	dir := t.TempDir()
+	key, err := GenerateKey(filepath.Join(dir, "cert.key"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GenerateKey(filepath.Join(dir, "other.key"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate := func(serial string, notBefore, notAfter time.Time) *x509.Certificate {
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "test", SerialNumber: serial},
+			NotBefore:    notBefore,
+			NotAfter:     notAfter,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crt, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crt
+	}
+
+	now := time.Now()
+	valid := certificate("CUIT 20285142084", now.Add(-time.Hour), now.Add(time.Hour))
+
+	tests := []struct {
+		name string
+		crt  *x509.Certificate
+		key  crypto.Signer
+		cuit int64
+		want error
+	}{
+		{"valido", valid, key, 20285142084, nil},
+		{"sin cuit", valid, key, 0, nil},
+		{"otra clave", valid, other, 20285142084, ErrKeyMismatch},
+		{"otra cuit", valid, key, 30500010912, ErrCUITMismatch},
+		{"serial sin cuit", certificate("ABC", now.Add(-time.Hour), now.Add(time.Hour)), key, 20285142084, ErrCUITMismatch},
+		{"vencido", certificate("CUIT 20285142084", now.Add(-2*time.Hour), now.Add(-time.Hour)), key, 20285142084, ErrCertExpired},
+		{"no vigente", certificate("CUIT 20285142084", now.Add(time.Hour), now.Add(2*time.Hour)), key, 20285142084, ErrCertNotYetValid},
+	}
+
+	for _, tt := range tests {
+		err := ValidateCertificate(tt.crt, tt.key, tt.cuit)
+		if tt.want == nil && err != nil || !errors.Is(err, tt.want) {
+			t.Errorf("%s: ValidateCertificate() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Environments para los que AFIP emite certificados (mismos valores que wsafip.Environment.String())
+const (
+	EnvironmentTesting    = "testing"
+	EnvironmentProduction = "production"
+)
+
+// Emisores de los certificados de AFIP (O=AFIP)
+const (
+	afipIssuerOrganization = "AFIP"
+	afipIssuerTesting      = "Computadores Test"
+	afipIssuerProduction   = "Computadores"
+)
+
+// Report es el resultado de Inspect
+type Report struct {
+	Subject       string    `json:"subject"`
+	Issuer        string    `json:"issuer"`
+	CUIT          int64     `json:"cuit"`
+	NotBefore     time.Time `json:"notBefore"`
+	NotAfter      time.Time `json:"notAfter"`
+	DaysRemaining int64     `json:"daysRemaining"`
+	Expired       bool      `json:"expired"`
+	KeyChecked    bool      `json:"keyChecked"`
+	KeyMatches    bool      `json:"keyMatches"`
+	AFIPIssued    bool      `json:"afipIssued"`
+	ChainVerified bool      `json:"chainVerified"`
+	Environment   string    `json:"environment"`
+	Warnings      []string  `json:"warnings,omitempty"`
+}
+
+// InspectOption configura Inspect
+type InspectOption func(*inspectConfig)
+
+type inspectConfig struct {
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	now           time.Time
+}
+
+// WithRoots verifica la cadena del certificado contra los certificados raíz indicados (y opcionalmente
+// intermedios) en lugar de los CA de AFIP incluidos en el paquete (ver AFIPRoots)
+func WithRoots(roots, intermediates *x509.CertPool) InspectOption {
+	return func(c *inspectConfig) {
+		c.roots = roots
+		c.intermediates = intermediates
+	}
+}
+
+// Inspect analiza el certificado de certFile y, si keyFile no es vacío, su correspondencia con la clave
+func Inspect(certFile, keyFile string, opts ...InspectOption) (*Report, error) {
+	certData, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("Inspect: crt file not found: %s", err)
+	}
+
+	certDecode, _ := pem.Decode(certData)
+	if certDecode == nil {
+		return nil, fmt.Errorf("Inspect: could not decode crt data")
+	}
+
+	crt, err := x509.ParseCertificate(certDecode.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Inspect: could not parse crt data: %s", err)
+	}
+
+	var key crypto.Signer
+	var keyErr error
+	if keyFile != "" {
+		_, key, keyErr = LoadX509KeyPair(certFile, keyFile)
+	}
+
+	report := InspectCertificate(crt, key, opts...)
+	if keyErr != nil {
+		report.KeyChecked = true
+		report.Warnings = append(report.Warnings, keyErr.Error())
+	}
+	return report, nil
+}
+
+// InspectCertificate es como Inspect pero recibe el certificado y la clave (que puede ser nil)
+func InspectCertificate(crt *x509.Certificate, key crypto.Signer, opts ...InspectOption) *Report {
+	config := inspectConfig{now: time.Now()}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	report := &Report{
+		Subject:   crt.Subject.String(),
+		Issuer:    crt.Issuer.String(),
+		NotBefore: crt.NotBefore,
+		NotAfter:  crt.NotAfter,
+		Expired:   config.now.After(crt.NotAfter),
+	}
+
+	if !report.Expired {
+		report.DaysRemaining = int64(crt.NotAfter.Sub(config.now).Hours() / 24)
+	}
+	if config.now.Before(crt.NotBefore) {
+		report.Warnings = append(report.Warnings, ErrCertNotYetValid.Error())
+	}
+
+	if cuit, err := CUIT(crt); err != nil {
+		report.Warnings = append(report.Warnings, err.Error())
+	} else {
+		report.CUIT = cuit
+		if !ValidCUIT(cuit) {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("invalid CUIT %d", cuit))
+		}
+	}
+
+	if key != nil {
+		report.KeyChecked = true
+		pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+		report.KeyMatches = ok && pub.Equal(crt.PublicKey)
+		if !report.KeyMatches {
+			report.Warnings = append(report.Warnings, ErrKeyMismatch.Error())
+		}
+	}
+
+	roots, err := config.roots, error(nil)
+	if roots == nil {
+		roots, err = AFIPRoots()
+	}
+	if err == nil {
+		_, err = crt.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: config.intermediates,
+			CurrentTime:   config.now,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+	}
+	report.ChainVerified = err == nil
+
+	// El emisor declarado solo se tiene en cuenta si la cadena verifica, cualquiera puede emitir
+	// un certificado con O=AFIP, CN=Computadores
+	environment := issuerEnvironment(crt)
+	switch {
+	case environment == "":
+		report.Warnings = append(report.Warnings, "certificate is not issued by AFIP")
+	case !report.ChainVerified:
+		report.Warnings = append(report.Warnings, fmt.Sprintf("could not verify the AFIP certificate chain: %s", err))
+	default:
+		report.Environment = environment
+		report.AFIPIssued = true
+	}
+
+	return report
+}
+
+// issuerEnvironment devuelve el environment del CA de AFIP que emitió el certificado, o vacío si no es de AFIP
+func issuerEnvironment(crt *x509.Certificate) string {
+	afip := false
+	for _, org := range crt.Issuer.Organization {
+		if org == afipIssuerOrganization {
+			afip = true
+		}
+	}
+	if !afip {
+		return ""
+	}
+
+	switch crt.Issuer.CommonName {
+	case afipIssuerTesting:
+		return EnvironmentTesting
+	case afipIssuerProduction:
+		return EnvironmentProduction
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInspectCertificateChain(t *testing.T) {
+	dir := t.TempDir()
+	newKey := func(name string) crypto.Signer {
+		key, err := GenerateKey(filepath.Join(dir, name), 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	caKey, prodKey, spoofKey, key := newKey("ca.key"), newKey("prod.key"), newKey("spoof.key"), newKey("cert.key")
+
+	create := func(template, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) *x509.Certificate {
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crt, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crt
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"AFIP"}, CommonName: "Computadores Test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	ca := create(caTemplate, caTemplate, caKey.Public(), caKey)
+	spoof := create(caTemplate, caTemplate, spoofKey.Public(), spoofKey)
+	prodTemplate := *caTemplate
+	prodTemplate.Subject.CommonName = "Computadores"
+	prod := create(&prodTemplate, &prodTemplate, prodKey.Public(), prodKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	roots.AddCert(prod)
+	// Pool que solo contiene el CA de producción
+	prodRoots := x509.NewCertPool()
+	prodRoots.AddCert(prod)
+
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test", SerialNumber: "CUIT 20111111112"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	tests := []struct {
+		name         string
+		crt          *x509.Certificate
+		opts         []InspectOption
+		wantIssued   bool
+		wantVerified bool
+		wantEnv      string
+	}{
+		{"issued by the testing CA", create(leafTemplate, ca, key.Public(), caKey), []InspectOption{WithRoots(roots, nil)}, true, true, EnvironmentTesting},
+		{"issued by the production CA", create(leafTemplate, prod, key.Public(), prodKey), []InspectOption{WithRoots(roots, nil)}, true, true, EnvironmentProduction},
+		// CA con el mismo nombre pero otra clave
+		{"spoofed issuer", create(leafTemplate, spoof, key.Public(), spoofKey), []InspectOption{WithRoots(roots, nil)}, false, false, ""},
+		{"CA not in the roots", create(leafTemplate, ca, key.Public(), caKey), []InspectOption{WithRoots(prodRoots, nil)}, false, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := InspectCertificate(tt.crt, key, tt.opts...)
+			if report.AFIPIssued != tt.wantIssued || report.ChainVerified != tt.wantVerified || report.Environment != tt.wantEnv {
+				t.Errorf("InspectCertificate() AFIPIssued = %v, ChainVerified = %v, Environment = %q, want %v, %v, %q (warnings %v)",
+					report.AFIPIssued, report.ChainVerified, report.Environment, tt.wantIssued, tt.wantVerified, tt.wantEnv, report.Warnings)
+			}
+			if !report.KeyMatches || report.CUIT != 20111111112 {
+				t.Errorf("InspectCertificate() KeyMatches = %v, CUIT = %d", report.KeyMatches, report.CUIT)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// LoadPKCS12 lee un archivo PKCS#12 (.p12/.pfx) protegido con password. Devuelve el certificado
+// de la clave privada y los demás certificados del archivo (intermedios, ver WithChain).
+func LoadPKCS12(file, password string) (*x509.Certificate, crypto.Signer, []*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("LoadPKCS12: file not found: %s", err)
+	}
+
+	crt, key, chain, err := DecodePKCS12(data, password)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("LoadPKCS12: %s", err)
+	}
+	return crt, key, chain, nil
+}
+
+// DecodePKCS12 es como LoadPKCS12 pero recibe el contenido del archivo. Admite tanto el formato
+// legacy (RC2/3DES con SHA-1) como el que genera OpenSSL 3 por defecto (PBES2 con AES y SHA-256).
+func DecodePKCS12(data []byte, password string) (*x509.Certificate, crypto.Signer, []*x509.Certificate, error) {
+	key, crt, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not decode PKCS#12 data: %s", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(crt.PublicKey) {
+		return nil, nil, nil, fmt.Errorf("PKCS#12 data does not contain the certificate of the private key")
+	}
+
+	return crt, signer, chain, nil
+}
+
+// LoadEncryptedX509KeyPair es como LoadX509KeyPair pero admite una clave PEM cifrada con password,
+// ya sea PKCS#8 (ENCRYPTED PRIVATE KEY, el formato por defecto de OpenSSL 3) o el cifrado tradicional
+// de openssl (encabezados Proc-Type/DEK-Info)
+func LoadEncryptedX509KeyPair(certFile, keyFile, password string) (*x509.Certificate, crypto.Signer, error) {
+	return loadX509KeyPair(certFile, keyFile, []byte(password))
+}
+
+// parseKeyBlock interpreta la clave privada del bloque PEM, descifrándola si es necesario
+func parseKeyBlock(block *pem.Block, password []byte) (crypto.Signer, error) {
+	encrypted := block.Type == "ENCRYPTED PRIVATE KEY" || x509.IsEncryptedPEMBlock(block)
+	if encrypted && password == nil {
+		return nil, fmt.Errorf("key is encrypted, a password is required")
+	}
+
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, password)
+		if err != nil {
+			return nil, fmt.Errorf("could not decrypt key: %s", err)
+		}
+		return signerKey(key)
+	}
+
+	// Cifrado PEM tradicional de openssl (obsoleto en crypto/x509, pero es el que suelen usar las claves existentes)
+	der := block.Bytes
+	if encrypted {
+		var err error
+		if der, err = x509.DecryptPEMBlock(block, password); err != nil {
+			return nil, fmt.Errorf("could not decrypt key: %s", err)
+		}
+	}
+	return parsePrivateKey(der)
+}
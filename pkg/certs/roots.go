@@ -0,0 +1,97 @@
+package certs
+
+import (
+	"crypto/x509"
+	"embed"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Certificados de los CA de AFIP (homologación y producción) que se incluyen en el paquete.
+// Ver afip/README.md.
+//
+//go:embed afip
+var afipCAFiles embed.FS
+
+var (
+	afipRootsOnce sync.Once
+	afipRoots     *x509.CertPool
+	afipRootsErr  error
+)
+
+// AFIPRoots devuelve los certificados de los CA de AFIP incluidos en el paquete, contra los que
+// Inspect verifica por defecto la cadena del certificado
+func AFIPRoots() (*x509.CertPool, error) {
+	afipRootsOnce.Do(func() {
+		afipRoots, afipRootsErr = loadCertPool(afipCAFiles, "afip")
+	})
+	return afipRoots, afipRootsErr
+}
+
+// loadCertPool lee los certificados (PEM o DER) de los archivos .crt, .cer y .pem de dir
+func loadCertPool(fsys fs.FS, dir string) (*x509.CertPool, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("AFIPRoots: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	count := 0
+	for _, entry := range entries {
+		switch strings.ToLower(path.Ext(entry.Name())) {
+		case ".crt", ".cer", ".pem":
+		default:
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("AFIPRoots: %s", err)
+		}
+		certificates, err := parseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("AFIPRoots: %s: %s", entry.Name(), err)
+		}
+		for _, crt := range certificates {
+			pool.AddCert(crt)
+			count++
+		}
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("AFIPRoots: no AFIP CA certificates bundled in pkg/certs/afip")
+	}
+	return pool, nil
+}
+
+// parseCertificates interpreta uno o más certificados PEM, o un certificado DER
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse crt data: %s", err)
+		}
+		certificates = append(certificates, crt)
+	}
+	if certificates != nil {
+		return certificates, nil
+	}
+
+	crt, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse crt data: %s", err)
+	}
+	return []*x509.Certificate{crt}, nil
+}
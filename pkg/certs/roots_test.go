@@ -0,0 +1,116 @@
+package certs
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadCertPool(t *testing.T) {
+	key, err := GenerateKey(filepath.Join(t.TempDir(), "ca.key"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"AFIP"}, CommonName: "Computadores Test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	tests := []struct {
+		name      string
+		fsys      fstest.MapFS
+		wantCerts int
+		wantErr   bool
+	}{
+		{"pem", fstest.MapFS{"afip/ca.crt": {Data: pemData}}, 1, false},
+		{"der", fstest.MapFS{"afip/ca.cer": {Data: der}}, 1, false},
+		{"pem extension", fstest.MapFS{"afip/ca.pem": {Data: pemData}}, 1, false},
+		{"other files ignored", fstest.MapFS{"afip/README.md": {Data: []byte("# AFIP")}, "afip/ca.crt": {Data: pemData}}, 1, false},
+		{"no certificates", fstest.MapFS{"afip/README.md": {Data: []byte("# AFIP")}}, 0, true},
+		{"invalid certificate", fstest.MapFS{"afip/ca.crt": {Data: []byte("no es un certificado")}}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := loadCertPool(tt.fsys, "afip")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadCertPool() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := len(pool.Subjects()); got != tt.wantCerts {
+				t.Errorf("loadCertPool() = %d certificates, want %d", got, tt.wantCerts)
+			}
+		})
+	}
+}
+
+// TestAFIPRoots verifica los CA de AFIP incluidos en afip/: deben estar los dos y cada uno debe
+// corresponder a su environment
+func TestAFIPRoots(t *testing.T) {
+	entries, err := afipCAFiles.ReadDir("afip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundled := false
+	for _, entry := range entries {
+		if !strings.EqualFold(entry.Name(), "README.md") {
+			bundled = true
+		}
+	}
+	if !bundled {
+		t.Skip("pkg/certs/afip has no AFIP CA certificates yet, see pkg/certs/afip/README.md")
+	}
+
+	if _, err := AFIPRoots(); err != nil {
+		t.Fatal(err)
+	}
+
+	environments := make(map[string]bool)
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), "README.md") {
+			continue
+		}
+		data, err := afipCAFiles.ReadFile("afip/" + entry.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		certificates, err := parseCertificates(data)
+		if err != nil {
+			t.Fatalf("%s: %s", entry.Name(), err)
+		}
+		for _, ca := range certificates {
+			if !ca.IsCA {
+				t.Errorf("%s: %s is not a CA certificate", entry.Name(), ca.Subject)
+			}
+			// Environment de un certificado emitido por este CA
+			if environment := issuerEnvironment(&x509.Certificate{Issuer: ca.Subject}); environment != "" {
+				environments[environment] = true
+			}
+		}
+	}
+
+	for _, environment := range []string{EnvironmentTesting, EnvironmentProduction} {
+		if !environments[environment] {
+			t.Errorf("AFIPRoots() has no CA for the %s environment", environment)
+		}
+	}
+}